@@ -0,0 +1,113 @@
+package shifter
+
+import (
+	"fmt"
+	"hash/fnv"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/flaw"
+)
+
+// AdvisoryLock will take a postgresql advisory lock around the migration
+// so only one instance migrates at a time when started together.
+//
+// Other instances wait for the lock and then compare the models again with the already migrated database.
+// wait is max time to wait for the lock, zero means wait till the lock is released.
+// Lock key is derived from the database name and the table models set in shifter.
+// Lock is held by a separate transaction so the connection pool should have at least 2 connections.
+func (s *Shifter) AdvisoryLock(enable bool, wait time.Duration) *Shifter {
+	s.advisoryLock, s.lockWait = enable, wait
+	return s
+}
+
+//AdvisoryLockKey will set key of advisory lock instead of deriving it from database and table models
+func (s *Shifter) AdvisoryLockKey(key int64) *Shifter {
+	s.lockKey = key
+	return s
+}
+
+//withAdvisoryLock will run fn holding the advisory lock.
+//Lock is held by a separate transaction till fn returns. Nested calls use the same lock
+func (s *Shifter) withAdvisoryLock(conn *pg.DB, fn func() error) (err error) {
+	//every public run is wrapped in advisory lock so approvals are cleared after the outermost run
+	s.runDepth++
+	defer s.endRun()
+	if s.advisoryLock == false || s.lockHeld || s.plan {
+		return fn()
+	}
+	var lockTx *pg.Tx
+	if err = checkLockPoolSize(conn.Options().PoolSize); err != nil {
+		return
+	}
+	if lockTx, err = s.acquireAdvisoryLock(conn); err == nil {
+		s.lockHeld = true
+		err = fn()
+		s.lockHeld = false
+		lockTx.Rollback()
+	}
+	return
+}
+
+//checkLockPoolSize will return error if pool can't have lock transaction and migration transaction together
+//as the migration would wait for a connection held by the lock transaction forever
+func checkLockPoolSize(poolSize int) (err error) {
+	if poolSize < 2 {
+		err = fmt.Errorf("Advisory lock needs connection pool size of at least 2, got %v", poolSize)
+	}
+	return
+}
+
+//acquireAdvisoryLock will begin a transaction and take transaction level advisory lock in it
+func (s *Shifter) acquireAdvisoryLock(conn *pg.DB) (lockTx *pg.Tx, err error) {
+	var (
+		key      int64
+		acquired bool
+		sql      string
+	)
+	if lockTx, err = conn.Begin(); err != nil {
+		err = flaw.TxError(err)
+		return
+	}
+	if key, err = s.getAdvisoryLockKey(lockTx); err == nil {
+		sql = "SELECT pg_try_advisory_xact_lock(?)"
+		if _, err = lockTx.QueryOne(pg.Scan(&acquired), sql, key); err == nil && acquired == false {
+			fmt.Printf("Waiting for advisory lock %v held by another instance\n", key)
+			//lock_timeout is applicable on advisory lock as well
+			if s.lockWait > 0 {
+				sql = fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", s.lockWait/time.Millisecond)
+				_, err = lockTx.Exec(sql)
+			}
+			if err == nil {
+				sql = "SELECT pg_advisory_xact_lock(?)"
+				_, err = lockTx.Exec(sql, key)
+			}
+		}
+	}
+	if err != nil {
+		err = getWrapError("migration", "advisory lock", sql, err)
+		lockTx.Rollback()
+	}
+	return
+}
+
+//getAdvisoryLockKey will return advisory lock key set in shifter or derived from database and table models
+func (s *Shifter) getAdvisoryLockKey(tx *pg.Tx) (key int64, err error) {
+	if key = s.lockKey; key == 0 {
+		var dbName string
+		if _, err = tx.QueryOne(pg.Scan(&dbName), "SELECT current_database()"); err == nil {
+			key = getLockKey(dbName, s.getTableNames())
+		}
+	}
+	return
+}
+
+//getLockKey will return fnv hash of database name and sorted table names
+func getLockKey(dbName string, tables []string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte(dbName))
+	for _, tableName := range tables {
+		h.Write([]byte("\x00" + tableName))
+	}
+	return int64(h.Sum64())
+}
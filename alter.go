@@ -3,6 +3,7 @@ package shifter
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"strings"
 	"time"
 
@@ -17,34 +18,40 @@ func (s *Shifter) alterTable(tx *pg.Tx, tableName string,
 	skipPrompt bool) (err error) {
 
 	var (
-		tSchema           map[string]model.ColSchema
-		tUK               []model.UKSchema
-		idx               []model.Index
-		colAlter, ukAlter bool
+		tSchema map[string]model.ColSchema
+		tUK     []model.UKSchema
+		idx     []model.Index
+		isAlter bool
 	)
 	_, isValid := s.table[tableName]
 	defer s.logMode(false)
 
 	if isValid == true {
 
+		if s.isIgnoredTable(tableName) {
+			return
+		}
+
 		if tSchema, err = s.getTableSchema(tx, tableName); err == nil {
-			sSchema := s.GetStructSchema(tableName)
+			sSchema := s.removeIgnoredColumn(tableName, s.GetStructSchema(tableName))
 
-			if s.hisExists, err = util.IsAfterUpdateTriggerExists(tx, tableName); err == nil {
+			if s.hisExists, err = s.hasAfterUpdateTrigger(tx, tableName); err == nil {
 
-				//checking enum to update
-				if err = s.upsertAllEnum(tx, tableName); err == nil {
-					//checking column to update
-					if colAlter, err = s.compareSchema(tx, tSchema, sSchema, skipPrompt); err == nil {
-						//checking composite unique key to update
-						tUK, ukAlter, err = s.modifyCompositeUniqueKey(tx, tableName)
-						//TODO: check index to update
-					}
-					if err == nil && (colAlter || ukAlter) {
-						if idx, err = getDBIndex(tx, tableName); err == nil {
+				if err = s.beforeAlter(tx, tableName, tSchema, sSchema, skipPrompt); err == nil {
+					stepIdx, deferIdx := len(s.steps), len(s.deferred)
+					if tUK, isAlter, err = s.alterSchema(tx, tableName, tSchema, sSchema,
+						skipPrompt); err == nil && isAlter && s.plan == false {
+						if idx, err = s.getTableIndex(tx, tableName); err == nil {
+							idx = s.removeIgnoredIndex(tableName, idx)
 							err = s.createAlterStructLog(tSchema, tUK, idx, true)
 						}
 					}
+					if err == nil {
+						//run once data migrations after schema is altered
+						if err = s.runMigration(tx, tableName, skipPrompt); err == nil {
+							err = s.afterAlter(tx, tableName, stepIdx, deferIdx)
+						}
+					}
 				}
 			}
 		}
@@ -54,13 +61,33 @@ func (s *Shifter) alterTable(tx *pg.Tx, tableName string,
 	return
 }
 
+//alterSchema will alter enum, columns and composite unique keys of table by comparing with struct
+func (s *Shifter) alterSchema(tx *pg.Tx, tableName string, tSchema, sSchema map[string]model.ColSchema,
+	skipPrompt bool) (tUK []model.UKSchema, isAlter bool, err error) {
+
+	var colAlter, ukAlter bool
+	//checking enum to update
+	if err = s.upsertAllEnum(tx, tableName); err == nil {
+		//checking column to update
+		cSchema := s.removeIgnoredColumn(tableName, tSchema)
+		removeExpandColumn(cSchema, sSchema)
+		if colAlter, err = s.compareSchema(tx, cSchema, sSchema, skipPrompt); err == nil {
+			//checking composite unique key to update
+			tUK, ukAlter, err = s.modifyCompositeUniqueKey(tx, tableName)
+			//TODO: check index to update
+		}
+	}
+	isAlter = colAlter || ukAlter
+	return
+}
+
 //modifyCompositeUniqueKey will modify composite unique key if changed in struct
 func (s *Shifter) modifyCompositeUniqueKey(tx *pg.Tx,
 	tableName string) (tUK []model.UKSchema, isAlter bool, err error) {
 
 	defer func() { s.logMode(false) }()
 	sUK := s.getUKFromMethod(tableName)
-	if tUK, err = getDBCompositeUniqueKey(tx, tableName); err == nil &&
+	if tUK, err = s.getTableUK(tx, tableName); err == nil &&
 		(len(tUK) > 0 || len(sUK) > 0) {
 		s.logMode(s.verbose)
 		isAlter, err = s.checkUniqueKeyToAlter(tx, tableName, tUK, sUK)
@@ -75,10 +102,15 @@ func (s *Shifter) getTableSchema(tx *pg.Tx, tableName string) (
 		columnSchema []model.ColSchema
 		constraint   []model.ColSchema
 	)
+	var tc *tableCatalog
 	s.logMode(false)
-	if columnSchema, err = getColumnSchema(tx, tableName); err == nil {
-		if constraint, err = getConstraint(tx, tableName); err == nil {
-			tSchema = mergeColumnConstraint(tableName, columnSchema, constraint)
+	if tc, err = s.getCatalogTable(tx, tableName); err == nil && tc != nil {
+		tSchema = mergeColumnConstraint(tableName, tc.column, tc.constraint)
+	} else if err == nil {
+		if columnSchema, err = getColumnSchema(tx, tableName); err == nil {
+			if constraint, err = getConstraint(tx, tableName); err == nil {
+				tSchema = mergeColumnConstraint(tableName, columnSchema, constraint)
+			}
 		}
 	}
 	return
@@ -151,7 +183,20 @@ func (s *Shifter) alterCol(tx *pg.Tx, schema model.ColSchema,
 func (s *Shifter) addCol(tx *pg.Tx, schema model.ColSchema,
 	skipPrompt bool) (isAlter bool, err error) {
 
-	dType := getAddColTypeSQL(schema)
+	//not null column with backfill is added as nullable
+	//and set not null after backfill
+	backfill := s.needBackfill(schema)
+	colSchema := schema
+	if backfill {
+		colSchema.IsNullable = yes
+	}
+	//expanding column is added as nullable without default
+	//so sync trigger can fill it from old column
+	if schema.MigrateFrom != "" {
+		colSchema.IsNullable = yes
+		colSchema.ColumnDefault = ""
+	}
+	dType := getAddColTypeSQL(colSchema)
 	sql := getAddColSQL(schema.TableName, schema.ColumnName, dType)
 	cSQL := getAddConstraintSQL(schema)
 
@@ -164,13 +209,23 @@ func (s *Shifter) addCol(tx *pg.Tx, schema model.ColSchema,
 	//checking history table exists
 	if s.hisExists {
 		hName := util.GetHistoryTableName(schema.TableName)
-		dType = getStructDataType(schema)
+		dType = getColumnType(schema)
 		sql += getAddColSQL(hName, schema.ColumnName, dType)
 	}
 	//history alter sql end
 
-	if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-		err = getWrapError(schema.TableName, "add column", sql, err)
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opAddColumn,
+		Rewrite: isVolatileDefault(schema),
+		Scan:    cSQL != "" || (colSchema.IsNullable == no && schema.ColumnDefault == ""), SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(schema.TableName, opAddColumn, sql, err)
+	} else if isAlter && schema.MigrateFrom != "" {
+		isAlter, err = s.expandCol(tx, schema, skipPrompt)
+	} else if isAlter && backfill {
+		err = s.deferBackfill(tx, schema)
+	}
+	if err == nil && isAlter {
+		err = s.afterColumnAdded(tx, schema)
 	}
 	return
 }
@@ -185,6 +240,9 @@ func getAddColSQL(tName, cName, dType string) (sql string) {
 func (s *Shifter) dropCol(tx *pg.Tx, schema model.ColSchema,
 	skipPrompt bool) (isAlter bool, err error) {
 
+	if s.softDrop {
+		return s.softDropCol(tx, schema, skipPrompt)
+	}
 	sql := getDropColSQL(schema.TableName, schema.ColumnName)
 	//checking history table exists
 	if s.hisExists {
@@ -193,8 +251,9 @@ func (s *Shifter) dropCol(tx *pg.Tx, schema model.ColSchema,
 	}
 	//history alter sql end
 
-	if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-		err = getWrapError(schema.TableName, "drop column", sql, err)
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opDropColumn, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(schema.TableName, opDropColumn, sql, err)
 	}
 	return
 }
@@ -268,6 +327,8 @@ func getConstraintTagByFlag(flag string) (tag string) {
 //getAddColTypeSQL will return add column type sql
 func getAddColTypeSQL(schema model.ColSchema) (dType string) {
 	dType = getStructDataType(schema)
+	dType += getIdentitySQL(schema)
+	dType += getGeneratedSQL(schema)
 	// dType += getUniqueDTypeSQL(schema.ConstraintType)
 	dType += getNullDTypeSQL(schema.IsNullable)
 	dType += getDefaultDTypeSQL(schema)
@@ -278,15 +339,21 @@ func getAddColTypeSQL(schema model.ColSchema) (dType string) {
 func getStructDataType(schema model.ColSchema) (dType string) {
 	var exists bool
 
-	if schema.SeqName != "" {
+	if schema.SeqName != "" && schema.Identity == "" {
 		dType = getSerialType(schema.SeqDataType)
 	} else if schema.DataType == userDefined {
 		dType = schema.UdtName
+	} else if schema.DataType == arrayType {
+		if dType = schema.FullType; dType == "" {
+			dType = formatType(schema.UdtName)
+		}
 	} else if dType, exists = rPGAlias[schema.DataType]; exists == false {
 		dType = schema.DataType
 	}
 	if schema.CharMaxLen != "" {
 		dType += "(" + schema.CharMaxLen + ")"
+	} else if schema.DataType != arrayType {
+		dType += getTypePrecision(schema)
 	}
 	return
 }
@@ -353,19 +420,28 @@ func (s *Shifter) modifyCol(tx *pg.Tx, tSchema, sSchema map[string]model.ColSche
 		var curIsAlter bool
 		if scSchema, exists := sSchema[col]; exists {
 
-			//modify data type
-			if curIsAlter, err = s.modifyDataType(tx, tcSchema, scSchema, skipPrompt); err != nil {
+			//modify generated column, generated column is recreated to change its type
+			if curIsAlter, err = s.modifyGenerated(tx, tcSchema, scSchema, skipPrompt); err != nil {
 				break
 			}
 			isAlter = isAlter || curIsAlter
 
-			//if data type is not modified then only modify default type
-			if curIsAlter == false {
-				if curIsAlter, err = s.modifyDefault(tx, tcSchema, scSchema, skipPrompt); err != nil {
+			if scSchema.Generated == "" {
+				//modify data type
+				if curIsAlter, err = s.modifyDataType(tx, tcSchema, scSchema, skipPrompt); err != nil {
 					break
 				}
+				isAlter = isAlter || curIsAlter
+
+				//if data type is not modified then only modify default type
+				//default of expanding column is set on contract
+				if curIsAlter == false && scSchema.MigrateFrom == "" {
+					if curIsAlter, err = s.modifyDefault(tx, tcSchema, scSchema, skipPrompt); err != nil {
+						break
+					}
+				}
+				isAlter = isAlter || curIsAlter
 			}
-			isAlter = isAlter || curIsAlter
 
 			//modify not null constraint
 			if curIsAlter, err = s.modifyNotNullConstraint(tx, tcSchema, scSchema, skipPrompt); err != nil {
@@ -373,6 +449,12 @@ func (s *Shifter) modifyCol(tx *pg.Tx, tSchema, sSchema map[string]model.ColSche
 			}
 			isAlter = isAlter || curIsAlter
 
+			//modify identity or serial after not null as identity column is not null
+			if curIsAlter, err = s.modifyIdentity(tx, tcSchema, scSchema, skipPrompt); err != nil {
+				break
+			}
+			isAlter = isAlter || curIsAlter
+
 			//modify pk/uk/fk constraint
 			if curIsAlter, err = s.modifyConstraint(tx, tcSchema, scSchema, skipPrompt); err != nil {
 				break
@@ -388,13 +470,20 @@ func (s *Shifter) modifyCol(tx *pg.Tx, tSchema, sSchema map[string]model.ColSche
 func (s *Shifter) modifyNotNullConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema,
 	skipPrompt bool) (isAlter bool, err error) {
 
-	if tSchema.IsNullable != sSchema.IsNullable {
-		option := set
+	if tSchema.IsNullable != sSchema.IsNullable && s.online && sSchema.IsNullable == no {
+		isAlter, err = s.onlineSetNotNull(tx, sSchema, skipPrompt)
+	} else if tSchema.IsNullable != sSchema.IsNullable {
+		option, op := set, opSetNotNull
 		if sSchema.IsNullable == yes {
-			option = drop
+			option, op = drop, opDropNotNull
 		}
 		sql := getNotNullColSQL(sSchema.TableName, sSchema.ColumnName, option)
-		if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
+		step := Step{Table: sSchema.TableName, Column: sSchema.ColumnName, Op: op,
+			Scan: op == opSetNotNull, SQL: sql}
+		if op == opSetNotNull {
+			step.checks = []dataCheck{getNullCheck(sSchema)}
+		}
+		if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
 			err = getWrapError(sSchema.TableName, "modify not null", sql, err)
 		}
 	}
@@ -412,39 +501,81 @@ func getNotNullColSQL(tName, cName, option string) (sql string) {
 func (s *Shifter) modifyDataType(tx *pg.Tx, tSchema, sSchema model.ColSchema,
 	skipPrompt bool) (isAlter bool, err error) {
 
-	tDataType := getStructDataType(tSchema)
-	sDataType := getStructDataType(sSchema)
-
-	// fmt.Println("DType", tSchema.ColumnName, "T", tDataType, "S", sDataType)
+	sDataType := getColumnType(sSchema)
 
-	if tDataType != sDataType {
-		//dropping default sql
-		sql := getDropDefaultSQL(sSchema.TableName, sSchema.ColumnName)
-		//modifying column type
-		sql += getModifyColSQL(sSchema.TableName, sSchema.ColumnName, sDataType, sDataType)
-		//adding back default sql
-		sql += getSetDefaultSQL(sSchema.TableName, sSchema.ColumnName, sSchema.ColumnDefault)
+	if isSameType(tSchema, sSchema) == false {
+		//metadata only change should not have using clause else table will be rewritten
+		class := getTypeChangeClass(tSchema, sSchema)
+		using := s.getUsing(tSchema, sSchema)
+		rewrite := class != SafeChange || using != ""
+		if rewrite && using == "" {
+			using = fmt.Sprintf("%v::text::%v", sSchema.ColumnName, sDataType)
+		}
+		sql := ""
+		if tSchema.SeqName == "" {
+			//dropping default sql
+			sql = getDropDefaultSQL(sSchema.TableName, sSchema.ColumnName)
+			//modifying column type
+			sql += getModifyColSQL(sSchema.TableName, sSchema.ColumnName, sDataType, using)
+			//adding back default sql
+			sql += getSetDefaultSQL(sSchema.TableName, sSchema.ColumnName, sSchema.ColumnDefault)
+		} else {
+			//sequence default of serial/identity column is kept and sequence type is changed with column
+			sql = getModifyColSQL(sSchema.TableName, sSchema.ColumnName, sDataType, using)
+			sql += getSeqTypeSQL(tSchema, sSchema)
+		}
 
 		//checking history table exists
 		if s.hisExists {
 			hName := util.GetHistoryTableName(sSchema.TableName)
-			sql += getModifyColSQL(hName, sSchema.ColumnName, sDataType, sDataType)
+			sql += getModifyColSQL(hName, sSchema.ColumnName, sDataType, using)
 		}
 		//history alter sql end
 
-		if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-			err = getWrapError(sSchema.TableName, "modify datatype", sql, err)
+		step := Step{Table: sSchema.TableName, Column: sSchema.ColumnName, Op: opModifyDataType,
+			Class: class, Rewrite: rewrite, Scan: rewrite, SQL: sql,
+			checks: s.getTypeChangeCheck(tSchema, sSchema)}
+		if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+			err = getWrapError(sSchema.TableName, opModifyDataType, sql, err)
 		}
 	}
 
 	return
 }
 
+//getUsing will return custom using expression of column type change from table type to struct type.
+//Using tag is applied only if table type matches using_from tag and Using(column, fromType, toType)
+//method associated to table structure is asked for the type pair,
+//so a one off conversion is not reused on later type change of the column
+func (s *Shifter) getUsing(tSchema, sSchema model.ColSchema) (using string) {
+	fromType, toType := getColumnType(tSchema), getColumnType(sSchema)
+	if sSchema.UsingFrom != "" &&
+		(sSchema.UsingFrom == fromType || sSchema.UsingFrom == typeModRegex.ReplaceAllString(fromType, "")) {
+		using = sSchema.Using
+	}
+	if dbModel, exists := s.table[sSchema.TableName]; exists {
+		refObj := reflect.ValueOf(dbModel)
+		m := refObj.MethodByName("Using")
+		if m.IsValid() && m.Type().NumIn() == 3 && m.Type().NumOut() == 1 {
+			out := m.Call([]reflect.Value{reflect.ValueOf(sSchema.ColumnName),
+				reflect.ValueOf(fromType), reflect.ValueOf(toType)})
+			if expr, ok := out[0].Interface().(string); ok && expr != "" {
+				using = expr
+			}
+		}
+	}
+	return
+}
+
 //getModifyColSQL will return modify column data type sql
-func getModifyColSQL(tName, cName, dType, udtType string) (sql string) {
+//using expression is optional
+func getModifyColSQL(tName, cName, dType, using string) (sql string) {
 
-	sql = fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v TYPE %v USING (%v::text::%v);\n",
-		tName, cName, dType, cName, udtType)
+	sql = fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v TYPE %v", tName, cName, dType)
+	if using != "" {
+		sql += fmt.Sprintf(" USING (%v)", using)
+	}
+	sql += ";\n"
 	return
 }
 
@@ -452,19 +583,20 @@ func getModifyColSQL(tName, cName, dType, udtType string) (sql string) {
 func (s *Shifter) modifyDefault(tx *pg.Tx, tSchema, sSchema model.ColSchema,
 	skipPrompt bool) (isAlter bool, err error) {
 
-	isSame := isSameDefault(tSchema, sSchema)
+	var isSame bool
 
 	//for primary key default is series so should remove it
-	if tSchema.ConstraintType != primaryKey &&
-		isSame == false {
+	if isSame, err = s.isSameColDefault(tx, tSchema, sSchema); err == nil &&
+		tSchema.ConstraintType != primaryKey && isSame == false {
 		sql := ""
 		if sSchema.ColumnDefault == "" {
 			sql = getDropDefaultSQL(sSchema.TableName, sSchema.ColumnName)
 		} else {
 			sql = getSetDefaultSQL(sSchema.TableName, sSchema.ColumnName, sSchema.ColumnDefault)
 		}
-		if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-			err = getWrapError(sSchema.TableName, "modify default", sql, err)
+		step := Step{Table: sSchema.TableName, Column: sSchema.ColumnName, Op: opModifyDefault, SQL: sql}
+		if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+			err = getWrapError(sSchema.TableName, opModifyDefault, sql, err)
 		}
 	}
 	return
@@ -505,6 +637,10 @@ func isSameDefault(tSchema, sSchema model.ColSchema) (isSame bool) {
 		if tDefault == sDefault {
 			isSame = true
 		}
+	} else if tSchema.SeqName != "" && (isSerialType(sSchema.DataType) || sSchema.Identity != "") {
+		//default of serial column is its sequence
+		//which is dropped by identity change if converted to identity
+		isSame = true
 	}
 	return
 }
@@ -560,35 +696,39 @@ func (s *Shifter) modifyConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema,
 	//if table and struct constraint doesn't match
 	if tSchema.ConstraintType != sSchema.ConstraintType {
 		if sSchema.ConstraintType == "" {
-			isAlter, err = dropColAllConstraints(tx, tSchema, sSchema, skipPrompt)
+			isAlter, err = s.dropColAllConstraints(tx, tSchema, sSchema, skipPrompt)
 		} else if tSchema.ConstraintType == "" {
-			isAlter, err = addColAllConstraints(tx, tSchema, sSchema, skipPrompt)
+			isAlter, err = s.addColAllConstraints(tx, tSchema, sSchema, skipPrompt)
 		} else {
-			isAlter, err = dropAndCreateConstraint(tx, tSchema, sSchema, skipPrompt)
+			isAlter, err = s.dropAndCreateConstraint(tx, tSchema, sSchema, skipPrompt)
 		}
 	} else if tSchema.ConstraintType == foreignKey {
-		isAlter, err = modifyFkAllConstraint(tx, tSchema, sSchema, skipPrompt)
+		isAlter, err = s.modifyFkAllConstraint(tx, tSchema, sSchema, skipPrompt)
+		//foreign key added in online mode but its validation is not completed
+		if err == nil && isAlter == false && s.online && tSchema.NotValid {
+			err = s.deferValidate(tx, tSchema, tSchema.ConstraintName)
+		}
 	}
 
 	if err == nil && isAlter == false {
-		isAlter, err = modifyDeferrable(tx, tSchema, sSchema, skipPrompt)
+		isAlter, err = s.modifyDeferrable(tx, tSchema, sSchema, skipPrompt)
 	}
 	return
 }
 
 //modifyFkAllConstraint will modify foreign key all constraints
-func modifyFkAllConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
+func (s *Shifter) modifyFkAllConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
 	isAlter bool, err error) {
-	if isAlter, err = modifyFkUniqueConstraint(tx, tSchema, sSchema, skipPrompt); err == nil {
+	if isAlter, err = s.modifyFkUniqueConstraint(tx, tSchema, sSchema, skipPrompt); err == nil {
 		var curAlter bool
-		curAlter, err = modifyFkConstraint(tx, tSchema, sSchema, skipPrompt)
+		curAlter, err = s.modifyFkConstraint(tx, tSchema, sSchema, skipPrompt)
 		isAlter = isAlter || curAlter
 	}
 	return
 }
 
 //modifyFkConstraint will modify foreign key of column if changed
-func modifyFkConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
+func (s *Shifter) modifyFkConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
 	isAlter bool, err error) {
 	//if foreign table or column changed
 	if tSchema.ForeignTableName != sSchema.ForeignTableName ||
@@ -596,34 +736,34 @@ func modifyFkConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt
 		tSchema.UpdateType != sSchema.UpdateType ||
 		tSchema.DeleteType != sSchema.DeleteType {
 
-		isAlter, err = dropAndCreateConstraint(tx, tSchema, sSchema, skipPrompt)
+		isAlter, err = s.dropAndCreateConstraint(tx, tSchema, sSchema, skipPrompt)
 	}
 	return
 }
 
 //dropAndCreateConstraint will drop current constraint and create new one
-func dropAndCreateConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
+func (s *Shifter) dropAndCreateConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
 	isAlter bool, err error) {
 	fmt.Println("---dropping old and creating new constraint---")
-	if isAlter, err = dropColAllConstraints(tx, tSchema, sSchema, skipPrompt); err == nil {
+	if isAlter, err = s.dropColAllConstraints(tx, tSchema, sSchema, skipPrompt); err == nil {
 		var curAlter bool
-		curAlter, err = addColAllConstraints(tx, tSchema, sSchema, skipPrompt)
+		curAlter, err = s.addColAllConstraints(tx, tSchema, sSchema, skipPrompt)
 		isAlter = isAlter || curAlter
 	}
 	return
 }
 
 //dropColConstraints will drop column all constraints
-func dropColAllConstraints(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
+func (s *Shifter) dropColAllConstraints(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
 	isAlter bool, err error) {
 
-	if isAlter, err = dropConstraint(tx, tSchema, skipPrompt); err == nil {
+	if isAlter, err = s.dropConstraint(tx, tSchema, skipPrompt); err == nil {
 		//TODO: also drop unique constraint if exists in table
 		//with foreign key
 		if tSchema.IsFkUnique && sSchema.IsFkUnique == false {
 			var curAtler bool
 			tSchema.ConstraintName = tSchema.FkUniqueName
-			curAtler, err = dropConstraint(tx, tSchema, skipPrompt)
+			curAtler, err = s.dropConstraint(tx, tSchema, skipPrompt)
 			isAlter = isAlter || curAtler
 		}
 	}
@@ -632,29 +772,30 @@ func dropColAllConstraints(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipProm
 
 //modifyFkUniqueConstraint will modify unique key constraint
 //if exists with foreign key on same column
-func modifyFkUniqueConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema,
+func (s *Shifter) modifyFkUniqueConstraint(tx *pg.Tx, tSchema, sSchema model.ColSchema,
 	skipPrompt bool) (isAlter bool, err error) {
 	if tSchema.IsFkUnique != sSchema.IsFkUnique {
 		if sSchema.IsFkUnique {
 			//adding unique constraint in table
 			//as its exists with foreign key
 			sSchema.ConstraintType = uniqueKey
-			isAlter, err = addConstraint(tx, sSchema, skipPrompt)
+			isAlter, err = s.addConstraint(tx, sSchema, skipPrompt)
 		} else if tSchema.IsFkUnique {
 			//droping unique constraint from table
 			//as its not exists with foreign key in struct anymore
 			tSchema.ConstraintName = tSchema.FkUniqueName
-			isAlter, err = dropConstraint(tx, tSchema, skipPrompt)
+			isAlter, err = s.dropConstraint(tx, tSchema, skipPrompt)
 		}
 	}
 	return
 }
 
 //dropConstraint will drop constraint from table
-func dropConstraint(tx *pg.Tx, tSchema model.ColSchema, skipPrompt bool) (isAlter bool, err error) {
+func (s *Shifter) dropConstraint(tx *pg.Tx, tSchema model.ColSchema, skipPrompt bool) (isAlter bool, err error) {
 	sql := getDropConstraintSQL(tSchema.TableName, tSchema.ConstraintName)
-	if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-		err = getWrapError(tSchema.TableName, "drop constraint", sql, err)
+	step := Step{Table: tSchema.TableName, Column: tSchema.ColumnName, Op: opDropConstraint, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(tSchema.TableName, opDropConstraint, sql, err)
 	}
 	return
 }
@@ -666,16 +807,16 @@ func getDropConstraintSQL(tName, constraintName string) (sql string) {
 }
 
 //addColAllConstraints will add column all constraints
-func addColAllConstraints(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
+func (s *Shifter) addColAllConstraints(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
 	isAlter bool, err error) {
 
-	if isAlter, err = addConstraint(tx, sSchema, skipPrompt); err == nil {
+	if isAlter, err = s.addConstraint(tx, sSchema, skipPrompt); err == nil {
 		//TODO: also adding unique constraint if exists in struct
 		//with foreign key
 		if sSchema.IsFkUnique && tSchema.IsFkUnique == false {
 			var curAtler bool
 			sSchema.ConstraintType = uniqueKey
-			curAtler, err = addConstraint(tx, sSchema, skipPrompt)
+			curAtler, err = s.addConstraint(tx, sSchema, skipPrompt)
 			isAlter = isAlter || curAtler
 		}
 	}
@@ -683,12 +824,22 @@ func addColAllConstraints(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPromp
 }
 
 //addConstraint will add constraint on table column
-func addConstraint(tx *pg.Tx, schema model.ColSchema, skipPrompt bool) (
+func (s *Shifter) addConstraint(tx *pg.Tx, schema model.ColSchema, skipPrompt bool) (
 	isAlter bool, err error) {
 
 	sql := getAlterAddConstraintSQL(schema)
-	if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-		err = getWrapError(schema.TableName, "add constraint", sql, err)
+	//in online mode foreign key is validated in separate transaction
+	online := s.online && schema.ConstraintType == foreignKey
+	if online {
+		sql = getNotValidSQL(sql)
+	}
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opAddConstraint,
+		Lock: getConstraintLock(schema), Scan: online == false, SQL: sql,
+		checks: getConstraintCheck(schema)}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(schema.TableName, opAddConstraint, sql, err)
+	} else if isAlter && online {
+		err = s.deferValidate(tx, schema, getConstraintName(schema))
 	}
 	return
 }
@@ -712,7 +863,7 @@ func getAddConstraintSQL(schema model.ColSchema) (sql string) {
 }
 
 //modifyDeferrable will modify add/drop constraint deferrable
-func modifyDeferrable(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
+func (s *Shifter) modifyDeferrable(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bool) (
 	isAlter bool, err error) {
 
 	// fmt.Println(tSchema.ColumnName, "T", tSchema.IsDeferrable, "S", sSchema.IsDeferrable)
@@ -721,8 +872,9 @@ func modifyDeferrable(tx *pg.Tx, tSchema, sSchema model.ColSchema, skipPrompt bo
 
 		sSchema.ConstraintName = tSchema.ConstraintName
 		sql := getDeferrableSQL(sSchema)
-		if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-			err = getWrapError(tSchema.TableName, "modify deferrable", sql, err)
+		step := Step{Table: tSchema.TableName, Column: tSchema.ColumnName, Op: opModifyDeferrable, SQL: sql}
+		if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+			err = getWrapError(tSchema.TableName, opModifyDeferrable, sql, err)
 		}
 	}
 	return
@@ -747,22 +899,53 @@ func getDeferrableSQL(schema model.ColSchema) (sql string) {
 	return
 }
 
+//wrapError is error with table, operation and sql for better debugging.
+//Actual error is kept to check postgresql error code
+type wrapError struct {
+	msg string
+	err error
+}
+
+//Error will return wrapped error message
+func (e *wrapError) Error() string {
+	return e.msg
+}
+
 //getWrapError will return wrapped error for better debugging
 func getWrapError(tName, op string, sql string, err error) (werr error) {
 	msg := fmt.Sprintf("%v %v error %v\nSQL: %v",
 		tName, op, err.Error(), sql)
-	werr = errors.New(msg)
+	werr = &wrapError{msg: msg, err: err}
 	return
 }
 
-//execByChoice will execute by choice
-func execByChoice(tx *pg.Tx, sql string, skipPrompt bool) (
+//execByChoice will execute step by choice if not blocked by policy.
+//In plan mode step is only recorded
+func (s *Shifter) execByChoice(tx *pg.Tx, step Step, skipPrompt bool) (
 	isAlter bool, err error) {
 
-	choice := util.GetChoice(sql, skipPrompt)
-	if choice == util.Yes {
+	var blocked bool
+	if err = s.annotateStep(tx, &step); err == nil {
+		if blocked = s.guardStep(&step); blocked == false {
+			blocked, err = s.preCheck(tx, &step)
+		}
+	}
+	if err == nil && s.plan {
 		isAlter = true
-		_, err = tx.Exec(sql)
+		s.steps = append(s.steps, step)
+	} else if err == nil && blocked == false {
+		if err = s.checkBlocker(tx, step); err == nil {
+			choice := s.getChoice(step.SQL, skipPrompt)
+			if choice == util.Yes {
+				isAlter = true
+				if err = execStep(tx, step); err == nil {
+					s.steps = append(s.steps, step)
+				}
+				s.catalog.invalidate(step)
+			} else {
+				s.declined = true
+			}
+		}
 	}
 	return
 }
@@ -818,6 +1001,7 @@ func mergeColumnConstraint(tName string, columnSchema,
 			curColumnSchema.DeleteType = curConstraint.DeleteType
 			curColumnSchema.IsFkUnique = curConstraint.IsFkUnique
 			curColumnSchema.FkUniqueName = curConstraint.FkUniqueName
+			curColumnSchema.NotValid = curConstraint.NotValid
 		}
 		curColumnSchema.TableName = tName
 		ColSchema[curColumnSchema.ColumnName] = curColumnSchema
@@ -53,6 +53,21 @@ var pgToGoType = map[string]string{
 	"timestamp with time zone":    "time.Time",
 }
 
+//pgArrayToGoType is array udt name to golang slice type mapping.
+//Arrays of other types like enum are mapped to []string
+var pgArrayToGoType = map[string]string{
+	"_int2":        "[]int64",
+	"_int4":        "[]int64",
+	"_int8":        "[]int64",
+	"_float4":      "[]float64",
+	"_float8":      "[]float64",
+	"_numeric":     "[]float64",
+	"_bool":        "[]bool",
+	"_date":        "[]time.Time",
+	"_timestamp":   "[]time.Time",
+	"_timestamptz": "[]time.Time",
+}
+
 //createAlterStructLog will create alter struct log
 func (s *Shifter) createAlterStructLog(schema map[string]model.ColSchema,
 	ukSchema []model.UKSchema, idx []model.Index, wt bool) (err error) {
@@ -207,14 +222,17 @@ func execLogTmpl(log sLog) (logStr string, err error) {
 //getTmplFunc will return template functions
 func getLogTmplFunc() template.FuncMap {
 	return template.FuncMap{
-		"Title":     getFieldName,
-		"getSQLTag": getSQLTag,
+		"Title":        getFieldName,
+		"getSQLTag":    getSQLTag,
+		"getSQLOption": getSQLOption,
 	}
 }
 
 //getSQLTag will return struct sql tag from schema struct
 func getSQLTag(schema model.ColSchema) (dType string) {
 	dType = getStructDataType(schema)
+	dType += getIdentitySQL(schema)
+	dType += getGeneratedSQL(schema)
 	dType += getNullDTypeSQL(schema.IsNullable)
 	dType += getDefaultDTypeSQL(schema)
 	dType += getUniqueDTypeSQL(schema)
@@ -222,6 +240,15 @@ func getSQLTag(schema model.ColSchema) (dType string) {
 	return
 }
 
+//getSQLOption will return go-pg option of struct sql tag.
+//Array column needs array option else go-pg encodes slice as json
+func getSQLOption(schema model.ColSchema) (option string) {
+	if schema.DataType == arrayType {
+		option = ",array"
+	}
+	return
+}
+
 //getConstraintTagSQL will return sql tag constraint
 func getConstraintTagSQL(schema model.ColSchema) (sql string) {
 	switch schema.ConstraintType {
@@ -239,14 +266,18 @@ func getTableNameFromStruct(model interface{}) string {
 }
 
 //GetStructFieldType will return struct field type from schema datatype
-func (l *sLog) GetStructFieldType(dataType string) (sType string) {
+func (l *sLog) GetStructFieldType(schema model.ColSchema) (sType string) {
 	var exists bool
-	if sType, exists = pgToGoType[dataType]; exists == false {
+	if schema.DataType == arrayType {
+		if sType, exists = pgArrayToGoType[schema.UdtName]; exists == false {
+			sType = "[]string"
+		}
+	} else if sType, exists = pgToGoType[schema.DataType]; exists == false {
 		sType = "interface{}"
 	}
 	//if any package is used then adding that in import
 	if strings.Contains(sType, ".") {
-		pkg := strings.Split(sType, ".")[0]
+		pkg := strings.Split(strings.TrimPrefix(sType, "[]"), ".")[0]
 		l.importedPkg["\""+pkg+"\""] = struct{}{}
 	}
 	return
@@ -311,7 +342,7 @@ type {{ .StructNameWT }} struct {
 	{{ else -}}
 		{{ $value.StructColumnName -}}
 	{{ end -}}
-	{{print " "}} {{ $.GetStructFieldType $value.DataType }}` + " `sql:\"{{ .ColumnName }},type:{{ getSQLTag $value }}\"`" + `
+	{{print " "}} {{ $.GetStructFieldType $value }}` + " `sql:\"{{ .ColumnName }}{{ getSQLOption $value }},type:{{ getSQLTag $value }}\"`" + `
 {{- end }}
 }
 
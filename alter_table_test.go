@@ -1,9 +1,15 @@
 package shifter
 
 import (
+	"errors"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-pg/pg"
 	"github.com/mayur-tolexo/contour/adapter/psql"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
+	"github.com/mayur-tolexo/pg-shifter/model"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -204,3 +210,918 @@ func getAllTypeStruct() []interface{} {
 	}
 	return diffStruct
 }
+
+func TestTypeChangeClass(t *testing.T) {
+	assert := assert.New(t)
+	col := func(dType, maxLen string) model.ColSchema {
+		return model.ColSchema{DataType: dType, CharMaxLen: maxLen}
+	}
+	assert.Equal(SafeChange, getTypeChangeClass(col("character varying", "100"), col("character varying", "255")))
+	assert.Equal(DestructiveChange, getTypeChangeClass(col("character varying", "255"), col("character varying", "100")))
+	assert.Equal(SafeChange, getTypeChangeClass(col("character varying", "255"), col("text", "")))
+	assert.Equal(DestructiveChange, getTypeChangeClass(col("text", ""), col("character varying", "100")))
+	assert.Equal(RiskyChange, getTypeChangeClass(col("integer", ""), col("bigint", "")))
+	assert.Equal(DestructiveChange, getTypeChangeClass(col("bigint", ""), col("integer", "")))
+	assert.Equal(RiskyChange, getTypeChangeClass(col("uuid", ""), col("text", "")))
+	assert.Equal(DestructiveChange, getTypeChangeClass(col("text", ""), col("uuid", "")))
+}
+
+func TestGuardDestructive(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter().GuardDestructive(true).AllowDestructive("test_address")
+
+	drop := Step{Table: "test_user", Column: "name", Op: opDropColumn, SQL: "ALTER TABLE test_user DROP name;"}
+	assert.True(s.guardStep(&drop))
+	assert.Equal(DestructiveChange, drop.Class)
+
+	add := Step{Table: "test_user", Column: "name", Op: opAddColumn}
+	assert.False(s.guardStep(&add))
+
+	allowed := Step{Table: "test_address", Column: "city", Op: opDropColumn}
+	assert.False(s.guardStep(&allowed))
+
+	err := s.getBlockedError()
+	assert.Error(err)
+	assert.Len(err.(*PolicyError).Blocked, 1)
+	assert.NoError(s.getBlockedError())
+}
+
+func TestModifyColSQL(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("ALTER TABLE test_user ALTER COLUMN name TYPE varchar(500);\n",
+		getModifyColSQL("test_user", "name", "varchar(500)", ""))
+	assert.Equal("ALTER TABLE test_user ALTER COLUMN age TYPE bigint USING (age::text::bigint);\n",
+		getModifyColSQL("test_user", "age", "bigint", "age::text::bigint"))
+}
+
+func TestLintRule(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter().Lint(NoRewrite(1000), NoFullScan(5000))
+
+	small := Step{Table: "test_user", Op: opModifyDataType, Rewrite: true, RowCount: 10}
+	assert.False(s.guardStep(&small))
+
+	big := Step{Table: "test_user", Op: opModifyDataType, Lock: AccessExclusiveLock,
+		Rewrite: true, RowCount: 2000}
+	assert.True(s.guardStep(&big))
+	assert.Contains(big.Reason, "rewrite")
+
+	scan := Step{Table: "test_user", Op: opSetNotNull, Lock: AccessExclusiveLock,
+		Scan: true, RowCount: 10000}
+	assert.True(s.guardStep(&scan))
+	assert.Contains(scan.Reason, "full scan")
+
+	assert.Len(s.getBlockedError().(*PolicyError).Blocked, 2)
+}
+
+func TestVolatileDefault(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(isVolatileDefault(model.ColSchema{DataType: "bigserial"}))
+	assert.True(isVolatileDefault(model.ColSchema{DataType: "uuid", ColumnDefault: "gen_random_uuid()"}))
+	assert.False(isVolatileDefault(model.ColSchema{DataType: "timestamp", ColumnDefault: "now()"}))
+	assert.False(isVolatileDefault(model.ColSchema{DataType: "integer", ColumnDefault: "0"}))
+}
+
+type testPGError struct {
+	code string
+}
+
+func (e testPGError) Error() string            { return "ERROR #" + e.code }
+func (e testPGError) Field(field byte) string  { return e.code }
+func (e testPGError) IntegrityViolation() bool { return false }
+
+func TestIsRetryable(t *testing.T) {
+	assert := assert.New(t)
+	lockErr := getWrapError("test_user", opAddColumn, "", testPGError{code: lockNotAvailable})
+	assert.True(isRetryable(lockErr))
+	assert.True(isRetryable(getWrapError("test_user", opAddColumn, "", lockErr)))
+	assert.True(isRetryable(getWrapError("test_user", opAddColumn, "", &BlockerError{})))
+	assert.False(isRetryable(getWrapError("test_user", opAddColumn, "", testPGError{code: "57014"})))
+	assert.False(isRetryable(errors.New("other")))
+}
+
+func TestOnlineConstraint(t *testing.T) {
+	assert := assert.New(t)
+	schema := model.ColSchema{TableName: "test_user", ColumnName: "address_id",
+		ConstraintType: foreignKey, ForeignTableName: "test_address", ForeignColumnName: "address_id"}
+	sql := getNotValidSQL(getAlterAddConstraintSQL(schema))
+	assert.Contains(sql, "REFERENCES test_address(address_id)")
+	assert.True(strings.HasSuffix(sql, " NOT VALID;\n"))
+
+	s := NewShifter().Online(true)
+	assert.NoError(s.deferValidate(nil, schema, getConstraintName(schema)))
+	assert.Len(s.deferred, 1)
+	assert.Equal("ALTER TABLE test_user VALIDATE CONSTRAINT test_user_address_id_fkey;\n", s.deferred[0].SQL)
+	assert.Equal(ShareUpdateExclusiveLock, s.deferred[0].Lock)
+}
+
+func TestPreCheckSQL(t *testing.T) {
+	assert := assert.New(t)
+	schema := model.ColSchema{TableName: "test_user", ColumnName: "name", CharMaxLen: "100"}
+	assert.Equal("SELECT count(*) FROM test_user t WHERE t.name IS NULL", getNullCheck(schema).count)
+	assert.Equal("SELECT count(*) FROM test_user t WHERE length(t.name::text) > 100", getLengthCheck(schema).count)
+
+	check := getDuplicateCheck("test_address", getUKColumns("address_line_1, city"))
+	assert.Equal("address_line_1, city", check.column)
+	assert.Equal("SELECT count(*) FROM (SELECT 1 FROM test_address WHERE address_line_1 IS NOT NULL "+
+		"AND city IS NOT NULL GROUP BY address_line_1, city HAVING count(*) > 1) d", check.count)
+
+	check = getEnumCheck(schema, []string{"active", "inactive"})
+	assert.Contains(check.count, "t.name::text NOT IN ('active','inactive')")
+
+	s := NewShifter()
+	narrow := s.getTypeChangeCheck(model.ColSchema{DataType: "character varying", CharMaxLen: "255"},
+		model.ColSchema{TableName: "test_user", ColumnName: "name", DataType: "character varying", CharMaxLen: "100"})
+	assert.Len(narrow, 1)
+	assert.Equal(LengthCheck, narrow[0].name)
+}
+
+func TestBackfill(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal("UPDATE test_user SET status = 'active' WHERE ctid = ANY(ARRAY("+
+		"SELECT ctid FROM test_user WHERE status IS NULL LIMIT 500));\n",
+		getBackfillSQL("test_user", "status", "'active'", 500))
+
+	s := NewShifter()
+	schema := model.ColSchema{TableName: "test_user", ColumnName: "status", IsNullable: no, Backfill: "'active'"}
+	assert.True(s.needBackfill(schema))
+	schema.ColumnDefault = "'active'"
+	assert.False(s.needBackfill(schema))
+	assert.False(s.needBackfill(model.ColSchema{TableName: "test_user", ColumnName: "status", IsNullable: no}))
+
+	assert.NoError(s.deferBackfill(nil, model.ColSchema{TableName: "test_user", ColumnName: "status",
+		IsNullable: no, Backfill: "'active'"}))
+	assert.Len(s.deferred, 2)
+	assert.Equal(opBackfill, s.deferred[0].Op)
+	assert.NotNil(s.deferred[0].run)
+	assert.Equal(opSetNotNull, s.deferred[1].Op)
+	assert.Equal(defaultBackfillBatch, s.getBatchSize())
+}
+
+type testUsing struct {
+	tableName struct{} `sql:"test_using"`
+	Active    bool     `sql:"active,type:boolean" using:"active = 'Y'" using_from:"varchar"`
+	CreatedAt int      `sql:"created_at,type:timestamp"`
+}
+
+func (testUsing) Using(column, fromType, toType string) string {
+	if column == "created_at" && fromType == "integer" {
+		return "to_timestamp(created_at)"
+	}
+	return ""
+}
+
+func TestUsing(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testUsing{})
+	sSchema := s.GetStructSchema("test_using")
+	assert.Equal("active = 'Y'", s.getUsing(model.ColSchema{FullType: "character varying(1)"}, sSchema["active"]))
+	intSchema := model.ColSchema{FullType: "integer"}
+	assert.Equal("to_timestamp(created_at)", s.getUsing(intSchema, sSchema["created_at"]))
+	assert.Equal("ALTER TABLE test_using ALTER COLUMN created_at TYPE timestamp USING (to_timestamp(created_at));\n",
+		getModifyColSQL("test_using", "created_at", "timestamp", s.getUsing(intSchema, sSchema["created_at"])))
+
+	//expression is not reused on later type change from other type
+	assert.Equal("", s.getUsing(model.ColSchema{FullType: "text"}, sSchema["active"]))
+	assert.Equal("", s.getUsing(model.ColSchema{FullType: "timestamp with time zone"}, sSchema["created_at"]))
+}
+
+type testExpand struct {
+	tableName struct{} `sql:"test_expand"`
+	FullName  string   `sql:"full_name,type:varchar(100)" from:"name"`
+	Amount    int64    `sql:"amount,type:bigint,notnull" from:"price" using:"price * 100"`
+}
+
+func TestExpand(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testExpand{})
+	sSchema := s.GetStructSchema("test_expand")
+	assert.Equal("name", s.getExpandExpr(sSchema["full_name"]))
+	assert.Equal("price * 100", s.getExpandExpr(sSchema["amount"]))
+
+	//renamed column is synced both ways
+	trigger := getSyncTrigger(sSchema["full_name"], "name")
+	assert.True(strings.Contains(trigger, "CREATE TRIGGER test_expand_full_name_sync"))
+	assert.True(strings.Contains(trigger, "NEW.name := NEW.full_name"))
+	trigger = getSyncTrigger(sSchema["amount"], "price * 100")
+	assert.False(strings.Contains(trigger, "NEW.price := NEW.amount"))
+
+	//long sync trigger name is truncated keeping it unique
+	table := strings.Repeat("t", 40)
+	name := getSyncTriggerName(table, strings.Repeat("c", 30))
+	assert.Equal(63, len(name))
+	assert.NotEqual(name, getSyncTriggerName(table, strings.Repeat("c", 31)))
+	assert.Equal("test_expand_full_name_sync", getSyncTriggerName("test_expand", "full_name"))
+
+	//old column is kept till contract
+	tSchema := map[string]model.ColSchema{"name": {ColumnName: "name"}, "amount": {ColumnName: "amount"}}
+	removeExpandColumn(tSchema, sSchema)
+	_, exists := tSchema["name"]
+	assert.False(exists)
+	assert.Equal("name", sSchema["full_name"].MigrateFrom)
+
+	//ignored table is not contracted
+	assert.NoError(NewShifter(&testExpand{}).Ignore(IgnoreTable, "test_expand").contractTable(nil, "test_expand", true))
+	assert.Equal("", sSchema["amount"].MigrateFrom)
+}
+
+func TestSoftDrop(t *testing.T) {
+	assert := assert.New(t)
+	at := time.Unix(1700000000, 0)
+	dropped := getDroppedName("status", at)
+	assert.Equal("status__dropped_1700000000", dropped)
+	name, dropAt, isValid := parseDroppedName(dropped)
+	assert.True(isValid)
+	assert.Equal("status", name)
+	assert.Equal(at, dropAt)
+	_, _, isValid = parseDroppedName("status")
+	assert.False(isValid)
+	assert.Len(getDroppedName(strings.Repeat("a", 60), at), maxIdentLen)
+
+	schema := model.ColSchema{TableName: "test_user", ColumnName: "email", ConstraintName: "test_user_email_key"}
+	assert.Equal("ALTER TABLE test_user DROP CONSTRAINT test_user_email_key;\n"+
+		"ALTER TABLE test_user ALTER COLUMN email DROP NOT NULL;\n"+
+		"ALTER TABLE test_user RENAME COLUMN email TO email__dropped_1700000000;\n",
+		getSoftDropColSQL(schema, getDroppedName("email", at)))
+
+	list := []droppedObject{{TableName: "test_user", Name: "email__dropped_1600000000"},
+		{TableName: "test_user", Name: dropped}, {TableName: "test_user", Name: "status__dropped_1600000000"}}
+	lastName, _, exists := getLastDropped(list, "test_user", "status")
+	assert.True(exists)
+	assert.Equal(dropped, lastName)
+
+	//long name truncated while dropping is restored by its full name
+	long := strings.Repeat("c", 60)
+	list = append(list, droppedObject{TableName: "test_user", Name: getDroppedName(long, at)})
+	lastName, _, exists = getLastDropped(list, "test_user", long)
+	assert.True(exists)
+	assert.Equal(getDroppedName(long, at), lastName)
+	_, _, exists = getLastDropped(list, "test_user", long[:40])
+	assert.False(exists)
+
+	//foreign keys dropped by cascade are recorded to add them back on restore
+	ref := []droppedReference{{TableName: "test_address", Name: "test_address_user_id_fkey",
+		Definition: "FOREIGN KEY (user_id) REFERENCES test_user(id) ON DELETE CASCADE"}}
+	sql := getSaveReferenceSQL("test_user'__dropped_1700000000", ref)
+	assert.True(strings.Contains(sql, "CREATE TABLE IF NOT EXISTS shifter_trash.shifter_reference"))
+	assert.True(strings.Contains(sql, "INSERT INTO shifter_trash.shifter_reference VALUES "+
+		"('test_user''__dropped_1700000000', 'test_address', 'test_address_user_id_fkey', "+
+		"'FOREIGN KEY (user_id) REFERENCES test_user(id) ON DELETE CASCADE');"))
+}
+
+type testHook struct {
+	tableName struct{} `sql:"test_hook"`
+	ID        int      `sql:"id,type:serial PRIMARY KEY"`
+	added     []string
+	result    TableResult
+}
+
+func (h *testHook) AfterColumnAdded(tx *pg.Tx, column string) error {
+	h.added = append(h.added, column)
+	return nil
+}
+
+func (h *testHook) AfterAlter(tx *pg.Tx, result TableResult) error {
+	h.result = result
+	return errors.New("data fix failed")
+}
+
+func TestHook(t *testing.T) {
+	assert := assert.New(t)
+	hook := &testHook{}
+	s := NewShifter(hook)
+	assert.NoError(s.afterColumnAdded(nil, model.ColSchema{TableName: "test_hook", ColumnName: "name"}))
+	assert.Equal([]string{"name"}, hook.added)
+	assert.NoError(s.beforeCreate(nil, "test_hook"))
+
+	s.steps = []Step{{Table: "test_other"}, {Table: "test_hook", Op: opAddColumn}}
+	err := s.afterAlter(nil, "test_hook", 1, 0)
+	assert.Error(err)
+	assert.True(strings.Contains(err.Error(), "AfterAlter hook"))
+	assert.Equal("test_hook", hook.result.Table)
+	assert.Len(hook.result.Steps, 1)
+}
+
+type testMigration struct {
+	tableName struct{} `sql:"test_migration"`
+	ID        int      `sql:"id,type:serial PRIMARY KEY"`
+}
+
+func (testMigration) Migrations() []Migration {
+	return []Migration{
+		{Name: "split_name", SQL: "UPDATE test_migration SET first_name = split_part(full_name, ' ', 1);"},
+		{Name: "fix_status", Func: func(tx *pg.Tx) error { return nil }},
+	}
+}
+
+func TestMigration(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testMigration{})
+	migration := s.getMigrationFromMethod("test_migration")
+	assert.Len(migration, 2)
+	assert.NoError(validateMigration("test_migration", migration))
+	assert.Error(validateMigration("test_migration", append(migration, Migration{Name: "split_name", SQL: "SELECT 1"})))
+	assert.Error(validateMigration("test_migration", []Migration{{Name: "empty"}}))
+
+	checksum := getMigrationChecksum(migration[0])
+	assert.Len(checksum, 64)
+	assert.NoError(s.checkMigrationChecksum("test_migration", "split_name", checksum, checksum))
+	assert.Error(s.checkMigrationChecksum("test_migration", "split_name", checksum, getMigrationChecksum(migration[1])))
+	assert.NoError(s.WarnChecksumChange(true).checkMigrationChecksum("test_migration", "split_name", checksum, ""))
+
+	//change of func migration is detected only by its version
+	fnMigration := Migration{Name: "fix_status", Func: func(tx *pg.Tx) error { return nil }}
+	checksum = getMigrationChecksum(fnMigration)
+	assert.Equal(getMigrationChecksum(Migration{Name: "fix_status"}), checksum)
+	fnMigration.Version = "2"
+	assert.NotEqual(checksum, getMigrationChecksum(fnMigration))
+}
+
+func TestSplitStatement(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal([]string{"ALTER TABLE test_user ALTER COLUMN status DROP DEFAULT;",
+		"ALTER TABLE test_user ALTER COLUMN status SET DEFAULT 'a;b';"},
+		splitStatement("ALTER TABLE test_user ALTER COLUMN status DROP DEFAULT;\n"+
+			"ALTER TABLE test_user ALTER COLUMN status SET DEFAULT 'a;b';\n"))
+
+	schema := model.ColSchema{TableName: "test_user", ColumnName: "full_name", MigrateFrom: "name"}
+	stmt := splitStatement(getSyncTrigger(schema, "name"))
+	assert.Len(stmt, 3)
+	assert.True(strings.Contains(stmt[0], "END;\n\t$$"))
+	assert.Empty(splitStatement("-- test_user.status backfill by Backfill() func in batches of 10\n"))
+
+	err := &StatementError{Step: Step{Table: "test_user", Op: opModifyDataType}, Index: 2, Total: 3,
+		Err: testPGError{code: lockNotAvailable}}
+	assert.True(isRetryable(getWrapError("test_user", opModifyDataType, "", err)))
+	assert.True(strings.Contains(err.Error(), "statement 2/3"))
+
+	//failed step of rolled back migration transaction is reported by Resume
+	sErr := getStatementError(&AllTableError{})
+	assert.Nil(sErr)
+	sErr = getStatementError(getWrapError("test_user", opModifyDataType, "", err))
+	assert.Equal(err, sErr)
+	failedErr := getFailedStepError([]Step{sErr.Step})
+	assert.True(strings.Contains(failedErr.Error(), "test_user"))
+	assert.True(strings.Contains(failedErr.Error(), "rerun alter"))
+}
+
+func TestAdvisoryLockKey(t *testing.T) {
+	assert := assert.New(t)
+	key := getLockKey("test_db", []string{"test_address", "test_user"})
+	assert.Equal(key, getLockKey("test_db", []string{"test_address", "test_user"}))
+	assert.NotEqual(key, getLockKey("other_db", []string{"test_address", "test_user"}))
+	assert.NotEqual(key, getLockKey("test_db", []string{"test_address"}))
+
+	s := NewShifter().AdvisoryLock(true, 0).AdvisoryLockKey(42)
+	key, err := s.getAdvisoryLockKey(nil)
+	assert.NoError(err)
+	assert.Equal(int64(42), key)
+
+	//lock transaction and migration transaction need separate connections
+	assert.Error(checkLockPoolSize(1))
+	assert.NoError(checkLockPoolSize(2))
+
+	//nested call doesn't take the lock again
+	s.lockHeld = true
+	assert.NoError(s.withAdvisoryLock(nil, func() error { return nil }))
+}
+
+func TestFingerprint(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testUsing{}, &testExpand{})
+	fingerprint := s.getFingerprint()
+	assert.Equal(fingerprint, NewShifter(&testExpand{}, &testUsing{}).getFingerprint())
+	assert.NotEqual(fingerprint, NewShifter(&testUsing{}).getFingerprint())
+	assert.NotEqual(s.getModelSet(), NewShifter(&testUsing{}).getModelSet())
+
+	s.SetEnum(map[string][]string{"status_type": {"active", "inactive"}})
+	assert.NotEqual(fingerprint, s.getFingerprint())
+
+	//alter options are part of fingerprint
+	fingerprint = s.getFingerprint()
+	for _, set := range []func(bool) *Shifter{s.Online, s.SoftDrop, s.GuardDestructive, s.SerialToIdentity} {
+		set(true)
+		assert.NotEqual(fingerprint, s.getFingerprint())
+		set(false)
+		assert.Equal(fingerprint, s.getFingerprint())
+	}
+
+	unchanged, err := s.isUnchanged(nil, fingerprint)
+	assert.NoError(err)
+	assert.False(unchanged)
+}
+
+func TestCatalog(t *testing.T) {
+	assert := assert.New(t)
+	c := newCatalog()
+	c.table["test_expand"], c.table["test_using"] = &tableCatalog{}, &tableCatalog{}
+	c.enum = map[string][]string{"status_type": {"active"}}
+	c.invalidate(Step{Table: "test_expand", Op: opAddColumn})
+	_, exists := c.table["test_expand"]
+	assert.False(exists)
+	assert.NotNil(c.enum)
+	c.invalidate(Step{Table: "test_using", Op: opAddEnumValue})
+	assert.Equal(0, len(c.table))
+	assert.Nil(c.enum)
+
+	//snapshot is same as per table queries
+	if conn, err := psql.Conn(true); err == nil {
+		s := NewShifter()
+		addAllTables(s)
+		var tx *pg.Tx
+		if tx, err = conn.Begin(); err == nil {
+			defer tx.Rollback()
+			for _, tableName := range s.getTableNames() {
+				s.catalog = nil
+				tSchema, err := s.getTableSchema(tx, tableName)
+				assert.NoError(err)
+				tUK, err := s.getTableUK(tx, tableName)
+				assert.NoError(err)
+				s.catalog = newCatalog()
+				cSchema, err := s.getTableSchema(tx, tableName)
+				assert.NoError(err)
+				cUK, err := s.getTableUK(tx, tableName)
+				assert.NoError(err)
+				assert.Equal(tSchema, cSchema)
+				assert.Equal(len(tUK), len(cUK))
+			}
+		}
+	}
+}
+
+func TestInspectSchema(t *testing.T) {
+	assert := assert.New(t)
+	con := []inspect.Constraint{
+		{Table: "test_user", Name: "test_user_pkey", Type: primaryKey, Columns: []string{"user_id"}, Validated: true},
+		{Table: "test_user", Name: "test_user_created_by_fkey", Type: foreignKey, Columns: []string{"created_by"},
+			ForeignTable: "test_user", ForeignColumns: []string{"user_id"}, Deferrable: true},
+		{Table: "test_user", Name: "test_user_name_email_key", Type: uniqueKey,
+			Columns: []string{"name", "email"}, Validated: true},
+	}
+	conSchema := toConstraintSchema(con)
+	if assert.Equal(2, len(conSchema)) {
+		assert.Equal("user_id", conSchema[0].ForeignColumnName)
+		assert.Equal(no, conSchema[0].IsDeferrable)
+		assert.Equal("user_id", conSchema[1].ForeignColumnName)
+		assert.Equal(yes, conSchema[1].IsDeferrable)
+		assert.True(conSchema[1].NotValid)
+	}
+	assert.Equal([]model.UKSchema{{ConstraintName: "test_user_name_email_key", Columns: "name,email"}},
+		toUKSchema(con))
+
+	column := toColumnSchema([]inspect.Column{{Name: "name", CharMaxLen: 100, Nullable: true},
+		{Name: "city" + droppedSuffix + "1", Nullable: true}})
+	if assert.Equal(1, len(column)) {
+		assert.Equal("100", column[0].CharMaxLen)
+		assert.Equal(yes, column[0].IsNullable)
+	}
+}
+
+func TestFormatType(t *testing.T) {
+	assert := assert.New(t)
+	types := map[string]string{
+		"int":                         "integer",
+		"serial":                      "integer",
+		"bigserial":                   "bigint",
+		"varchar(255)":                "character varying(255)",
+		"varchar":                     "character varying",
+		"char":                        "character(1)",
+		"numeric(10, 2)":              "numeric(10,2)",
+		"decimal(12)":                 "numeric(12,0)",
+		"float(10)":                   "real",
+		"float":                       "double precision",
+		"double precision":            "double precision",
+		"timestamp":                   "timestamp without time zone",
+		"timestamp(3)":                "timestamp(3) without time zone",
+		"timestamptz(3)":              "timestamp(3) with time zone",
+		"time(0) with time zone":      "time(0) with time zone",
+		"interval":                    "interval",
+		"bit":                         "bit(1)",
+		"varbit(8)":                   "bit varying(8)",
+		"int[]":                       "integer[]",
+		"_int4":                       "integer[]",
+		"text[][]":                    "text[]",
+		"varchar(10) array":           "character varying(10)[]",
+		"user_yesno_type":             "user_yesno_type",
+		"timestamp without time zone": "timestamp without time zone",
+	}
+	for cType, fType := range types {
+		assert.Equal(fType, formatType(cType), cType)
+	}
+
+	assert.Equal("numeric(10,2)", getTagType("amount,type:numeric(10,2) not null default 0"))
+	assert.Equal("timestamp(3) with time zone", getTagType("created_at,type:timestamp(3) with time zone default now()"))
+	assert.Equal("bigint", getTagType("amount,type:bigint,notnull"))
+	assert.Equal("serial", getTagType("id,type:serial primary key"))
+
+	tSchema := model.ColSchema{DataType: "numeric", FullType: "numeric(10,2)"}
+	sSchema := model.ColSchema{DataType: "numeric", CharMaxLen: "12,4", FullType: formatType("numeric(12,4)")}
+	assert.False(isSameType(tSchema, sSchema))
+	sSchema.FullType = formatType("decimal(10,2)")
+	assert.True(isSameType(tSchema, sSchema))
+}
+
+func TestNormalizeDefault(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter()
+	//same text is not normalized by database
+	tSchema := model.ColSchema{ColumnDefault: "'no'::user_yesno_type", IsNullable: no}
+	sSchema := model.ColSchema{ColumnDefault: "'no'", DataType: "user_yesno_type", DefaultExists: true}
+	isSame, err := s.isSameColDefault(nil, tSchema, sSchema)
+	assert.NoError(err)
+	assert.True(isSame)
+
+	assert.Equal("current_timestamp", normalizeExpr("now()"))
+	assert.Equal("current_timestamp", normalizeExpr("CURRENT_TIMESTAMP"))
+	assert.Equal("current_date", normalizeExpr("('now'::text)::date"))
+	assert.Equal("to_tsvector('english',coalesce(title,''))",
+		normalizeExpr("to_tsvector('english'::regconfig, COALESCE(title, ''::text))"))
+	assert.Equal("(a+1)*2", normalizeExpr("((a + 1) * 2)"))
+	assert.Equal("'A B'", normalizeExpr("'A B'::character varying"))
+
+	//verify and alter agree on function defaults without database
+	tSchema = model.ColSchema{TableName: "test_user", ColumnName: "created_at", ColumnDefault: "now()",
+		DataType: "timestamp without time zone", IsNullable: no}
+	sSchema = model.ColSchema{TableName: "test_user", ColumnName: "created_at", ColumnDefault: "CURRENT_TIMESTAMP",
+		DataType: "timestamp without time zone", IsNullable: no, DefaultExists: true}
+	isSame, err = s.isSameColDefault(nil, tSchema, sSchema)
+	assert.NoError(err)
+	assert.True(isSame)
+	diff, err := s.getColumnDiff(nil, tSchema, sSchema)
+	assert.NoError(err)
+	assert.Len(diff, 0)
+	isAlter, err := s.modifyDefault(nil, tSchema, sSchema, true)
+	assert.NoError(err)
+	assert.False(isAlter)
+
+	sSchema.ColumnDefault = "clock_timestamp()"
+	isSame, err = s.isSameColDefault(nil, tSchema, sSchema)
+	assert.NoError(err)
+	assert.False(isSame)
+	diff, err = s.getColumnDiff(nil, tSchema, sSchema)
+	assert.NoError(err)
+	assert.Len(diff, 1)
+}
+
+func TestConstDefault(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter()
+	assert.True(isConstDefault("'-1'::integer"))
+	assert.True(isConstDefault("'(a);'::text"))
+	assert.True(isConstDefault("'current_date'::text"))
+	assert.False(isConstDefault("now()"))
+	assert.False(isConstDefault("CURRENT_TIMESTAMP"))
+	assert.False(isConstDefault("1; DROP TABLE test_user"))
+
+	if conn, err := psql.Conn(true); err == nil {
+		var tx *pg.Tx
+		if tx, err = conn.Begin(); err == nil {
+			defer tx.Rollback()
+			_, err = tx.Exec("SET TRANSACTION READ ONLY")
+			assert.NoError(err)
+			//constants are compared by value in read only transaction
+			tSchema := model.ColSchema{ColumnDefault: "'{}'::jsonb", IsNullable: no}
+			sSchema := model.ColSchema{ColumnDefault: "'{}'", FullType: "jsonb", DefaultExists: true}
+			isSame, err := s.isSameColDefault(tx, tSchema, sSchema)
+			assert.NoError(err)
+			assert.True(isSame)
+			tSchema.ColumnDefault = "'-1'::integer"
+			sSchema = model.ColSchema{ColumnDefault: "-1", FullType: "integer", DefaultExists: true}
+			isSame, err = s.isSameColDefault(tx, tSchema, sSchema)
+			assert.NoError(err)
+			assert.True(isSame)
+			sSchema.ColumnDefault = "-2"
+			isSame, err = s.isSameColDefault(tx, tSchema, sSchema)
+			assert.NoError(err)
+			assert.False(isSame)
+			//invalid cast is reported as changed without aborting transaction
+			sSchema.ColumnDefault = "'abc'"
+			isSame, err = s.isSameColDefault(tx, tSchema, sSchema)
+			assert.NoError(err)
+			assert.False(isSame)
+		}
+	}
+}
+
+type testArray struct {
+	tableName struct{}  `sql:"test_array"`
+	Tags      []string  `sql:"tags,array,type:varchar(10)[] not null default '{}'"`
+	Scores    []int64   `sql:"scores,type:int[],array"`
+	Rates     []float64 `sql:"rates,type:numeric(10,2) array"`
+}
+
+func TestArray(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testArray{})
+	sSchema := s.GetStructSchema("test_array")
+	assert.Equal(arrayType, sSchema["tags"].DataType)
+	assert.Equal("", sSchema["tags"].CharMaxLen)
+	assert.Equal("character varying(10)[]", sSchema["tags"].FullType)
+	assert.Equal("'{}'", sSchema["tags"].ColumnDefault)
+	assert.Equal("integer[]", sSchema["scores"].FullType)
+	assert.Equal("numeric(10,2)[]", sSchema["rates"].FullType)
+	assert.Equal("character varying(10)[] NOT NULL DEFAULT '{}'", getAddColTypeSQL(sSchema["tags"]))
+
+	//array as read from database
+	tSchema := model.ColSchema{ColumnName: "scores", DataType: arrayType, UdtName: "_int4", FullType: "integer[]"}
+	assert.True(isSameType(tSchema, sSchema["scores"]))
+	assert.False(isSameType(tSchema, sSchema["tags"]))
+	assert.Equal("integer[]", getBaseType(tSchema))
+	assert.Equal(DestructiveChange, getTypeChangeClass(sSchema["tags"], tSchema))
+
+	l := sLog{importedPkg: make(map[string]struct{})}
+	assert.Equal("[]int64", l.GetStructFieldType(tSchema))
+	tSchema.UdtName = "_timestamptz"
+	assert.Equal("[]time.Time", l.GetStructFieldType(tSchema))
+	_, imported := l.importedPkg[`"time"`]
+	assert.True(imported)
+	tSchema.UdtName = "_user_yesno_type"
+	assert.Equal("[]string", l.GetStructFieldType(tSchema))
+	assert.Equal(",array", getSQLOption(tSchema))
+}
+
+type testPrecision struct {
+	tableName struct{}  `sql:"test_precision"`
+	Amount    float64   `sql:"amount,type:decimal(14,4) not null default 0"`
+	Rate      float64   `sql:"rate,type:numeric(5)"`
+	Total     float64   `sql:"total,type:numeric"`
+	CreatedAt time.Time `sql:"created_at,type:timestamp(3) with time zone"`
+	Duration  string    `sql:"duration,type:interval(2)"`
+}
+
+func TestPrecision(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testPrecision{})
+	sSchema := s.GetStructSchema("test_precision")
+	amount := sSchema["amount"]
+	assert.Equal("numeric", amount.DataType)
+	assert.Equal("", amount.CharMaxLen)
+	assert.Equal("14", amount.NumericPrecision)
+	assert.Equal("4", amount.NumericScale)
+	assert.Equal("numeric(14,4)", getStructDataType(amount))
+	assert.Equal("0", sSchema["rate"].NumericScale)
+	assert.Equal("numeric", getStructDataType(sSchema["total"]))
+	assert.Equal("timestamp with time zone", sSchema["created_at"].DataType)
+	assert.Equal("3", sSchema["created_at"].DatetimePrecision)
+	assert.Equal("timestamptz(3)", getStructDataType(sSchema["created_at"]))
+	assert.Equal("interval(2)", getStructDataType(sSchema["duration"]))
+
+	//numeric(10,2) as read from database
+	tSchema := model.ColSchema{TableName: "test_precision", ColumnName: "amount", DataType: "numeric",
+		UdtName: "numeric", FullType: "numeric(10,2)", NumericPrecision: "10", NumericScale: "2"}
+	assert.False(isSameType(tSchema, amount))
+	assert.Equal("numeric(10,2)", getStructDataType(tSchema))
+	assert.Equal(RiskyChange, getTypeChangeClass(tSchema, amount))
+	wider := amount
+	wider.NumericPrecision, wider.NumericScale = "12", "2"
+	assert.Equal(SafeChange, getTypeChangeClass(tSchema, wider))
+	narrow := amount
+	narrow.NumericPrecision, narrow.NumericScale = "9", "2"
+	assert.Equal(DestructiveChange, getTypeChangeClass(tSchema, narrow))
+	assert.Equal("SELECT count(*) FROM test_precision t WHERE abs(round(t.amount::numeric, 2)) >= 10 ^ (9 - 2)",
+		getPrecisionCheck(narrow).count)
+	checks := s.getTypeChangeCheck(tSchema, narrow)
+	if assert.Equal(1, len(checks)) {
+		assert.Equal(PrecisionCheck, checks[0].name)
+	}
+
+	//timestamptz without precision is 6
+	tSchema = model.ColSchema{DataType: "timestamp with time zone", FullType: "timestamp with time zone"}
+	assert.Equal(DestructiveChange, getTypeChangeClass(tSchema, sSchema["created_at"]))
+	assert.Equal(SafeChange, getTypeChangeClass(sSchema["created_at"], tSchema))
+}
+
+type testIdentity struct {
+	tableName struct{} `sql:"test_identity"`
+	ID        int64    `sql:"id,type:bigint generated always as identity primary key"`
+	Code      int      `sql:"code,type:int generated by default as identity"`
+	Seq       int      `sql:"seq,type:serial"`
+}
+
+func TestIdentity(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testIdentity{})
+	sSchema := s.GetStructSchema("test_identity")
+	id, code := sSchema["id"], sSchema["code"]
+	assert.Equal(identityAlways, id.Identity)
+	assert.Equal("bigint", id.FullType)
+	assert.Equal(identityByDefault, code.Identity)
+	assert.Equal(no, code.IsNullable)
+	assert.Equal("", sSchema["seq"].Identity)
+	assert.Equal("int GENERATED BY DEFAULT AS IDENTITY NOT NULL", getAddColTypeSQL(code))
+	assert.True(isVolatileDefault(code))
+
+	//identity column as read from database has its sequence
+	tSchema := model.ColSchema{TableName: "test_identity", ColumnName: "code", DataType: "integer",
+		FullType: "integer", IsNullable: no, SeqName: "test_identity_code_seq", SeqDataType: "integer",
+		Identity: identityAlways}
+	assert.Equal("int", getStructDataType(tSchema))
+	assert.True(isSameDefault(tSchema, code))
+
+	//serial to bigint keeps default and changes sequence type
+	tSchema.Identity = ""
+	assert.Equal("serial", getStructDataType(tSchema))
+	assert.Equal("ALTER SEQUENCE test_identity_code_seq AS bigint;\n", getSeqTypeSQL(tSchema, id))
+	assert.Equal("", getSeqTypeSQL(tSchema, code))
+	tSchema.ColumnDefault = "nextval('test_identity_code_seq'::regclass)"
+	assert.True(isSameDefault(tSchema, sSchema["seq"]))
+	assert.True(isSameDefault(tSchema, code))
+
+	assert.Equal("CREATE SEQUENCE IF NOT EXISTS test_identity_seq_seq AS integer OWNED BY test_identity.seq;\n"+
+		"SELECT setval('test_identity_seq_seq', COALESCE(max(seq), 0) + 1, false) FROM test_identity;\n"+
+		"ALTER TABLE test_identity ALTER COLUMN seq SET DEFAULT nextval('test_identity_seq_seq'::regclass);\n",
+		getAddSerialSQL(sSchema["seq"]))
+}
+
+type testGenerated struct {
+	tableName struct{} `sql:"test_generated"`
+	ID        int      `sql:"id,type:serial primary key"`
+	Title     string   `sql:"title,type:text not null"`
+	Search    string   `sql:"search,type:tsvector generated always as (to_tsvector('english', coalesce(title, '('))) stored"`
+}
+
+func TestGenerated(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testGenerated{})
+	sSchema := s.GetStructSchema("test_generated")
+	search := sSchema["search"]
+	assert.Equal("to_tsvector('english', coalesce(title, '('))", search.Generated)
+	assert.Equal("tsvector", search.FullType)
+	assert.False(search.DefaultExists)
+	assert.Equal("", sSchema["title"].Generated)
+	assert.Equal("tsvector GENERATED ALWAYS AS (to_tsvector('english', coalesce(title, '('))) STORED NULL",
+		getAddColTypeSQL(search))
+	assert.True(isVolatileDefault(search))
+	assert.True(isSameGeneratedExpr(model.ColSchema{Generated: "TO_TSVECTOR('english',  coalesce(title, '('))"}, search))
+
+	//expression deparsed by pg_get_expr is same without any ddl in alter and verify
+	tSchema := model.ColSchema{FullType: "tsvector", IsNullable: search.IsNullable,
+		Generated: "to_tsvector('english'::regconfig, COALESCE(title, '('::text))"}
+	assert.True(isSameGenerated(tSchema, search))
+	diff, err := s.getColumnDiff(nil, tSchema, search)
+	assert.NoError(err)
+	assert.Len(diff, 0)
+	tSchema.FullType = "text"
+	assert.False(isSameGenerated(tSchema, search))
+	tSchema = model.ColSchema{FullType: "tsvector", Generated: "to_tsvector('simple'::regconfig, title)"}
+	assert.False(isSameGenerated(tSchema, search))
+
+	//generated column is plain in history table so its old value is inserted
+	_, trigger := s.getUpdateTrigger("test_generated")
+	assert.True(strings.Contains(trigger, "OLD.search"))
+	assert.False(strings.Contains(trigger, "OLD.search <> NEW.search"))
+	assert.True(strings.Contains(trigger, "OLD.title <> NEW.title"))
+}
+
+func TestEnumDiff(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0, len(getEnumDiff("yesno", []string{"yes", "no"}, []string{"yes", "no"})))
+	//missing in database
+	diff := getEnumDiff("yesno", []string{"yes"}, []string{"yes", "no"})
+	if assert.Equal(1, len(diff)) {
+		assert.Equal("values", diff[0].Field)
+		assert.Equal("yes", diff[0].DB)
+		assert.Equal("yes,no", diff[0].Struct)
+	}
+	//extra in database
+	diff = getEnumDiff("yesno", []string{"yes", "no", "maybe"}, []string{"yes", "no"})
+	if assert.Equal(1, len(diff)) {
+		assert.Equal("values", diff[0].Field)
+	}
+	//different order
+	diff = getEnumDiff("yesno", []string{"no", "yes"}, []string{"yes", "no"})
+	if assert.Equal(1, len(diff)) {
+		assert.Equal("order", diff[0].Field)
+	}
+}
+
+func TestAllowDestructivePerRun(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter().GuardDestructive(true).AllowDestructive().AllowDestructive("test_user")
+	drop := Step{Table: "test_user", Op: opDropColumn}
+	err := s.withAdvisoryLock(nil, func() error {
+		//nested run like runTx inside runAllTx keeps the approval
+		return s.withAdvisoryLock(nil, func() error {
+			assert.False(s.guardStep(&drop))
+			return nil
+		})
+	})
+	assert.NoError(err)
+	assert.False(s.allowAll)
+	assert.Equal(0, len(s.allow))
+	assert.True(s.guardStep(&drop))
+}
+
+func TestRetryState(t *testing.T) {
+	assert := assert.New(t)
+	created := saveCreated()
+	enumCreated["test_retry_enum"] = struct{}{}
+	tableCreated["test_retry_table"] = true
+	restoreCreated(created)
+	_, exists := enumCreated["test_retry_enum"]
+	assert.False(exists)
+	assert.False(tableCreated["test_retry_table"])
+
+	//choice given in first attempt is reused on retry
+	s := NewShifter()
+	s.choice = map[string]string{"DROP TABLE test_user": "n"}
+	assert.Equal("n", s.getChoice("DROP TABLE test_user", true))
+	assert.Equal("yes", s.getChoice("DROP TABLE test_address", true))
+	assert.Equal("yes", s.choice["DROP TABLE test_address"])
+
+	//pending step declined while resuming is kept pending
+	executed := false
+	s.choice = map[string]string{"SELECT 1;": "n"}
+	s.deferred = []Step{{Table: "test_user", Op: opBackfill, SQL: "SELECT 1;",
+		run: func(conn *pg.DB) error { executed = true; return nil }}}
+	assert.NoError(s.runDeferred(nil, false))
+	assert.False(executed)
+
+	//table marked created in rolled back savepoint is not kept as created
+	if conn, err := psql.Conn(true); err == nil {
+		var tx *pg.Tx
+		if tx, err = conn.Begin(); err == nil {
+			defer tx.Rollback()
+			fnErr, err := s.savepointTx(tx, func() error {
+				tableCreated["test_savepoint_table"] = true
+				return errors.New("failed")
+			})
+			assert.NoError(err)
+			assert.Error(fnErr)
+			assert.False(tableCreated["test_savepoint_table"])
+		}
+	}
+}
+
+func TestInspectColumns(t *testing.T) {
+	assert := assert.New(t)
+	if conn, err := psql.Conn(true); err == nil {
+		var tx *pg.Tx
+		if tx, err = conn.Begin(); err == nil {
+			defer tx.Rollback()
+			_, err = tx.Exec(`CREATE TABLE test_inspect (id int GENERATED BY DEFAULT AS IDENTITY,
+			name varchar(50) NOT NULL DEFAULT 'none', amount numeric(10,2), tags text[],
+			created_at timestamptz(3), code char(4), search text GENERATED ALWAYS AS (lower(name)) STORED)`)
+			assert.NoError(err)
+			column, err := inspect.Columns(tx, "", "test_inspect")
+			assert.NoError(err)
+			var infoColumn []struct {
+				DataType  string `sql:"data_type"`
+				UdtName   string `sql:"udt_name"`
+				MaxLen    int    `sql:"character_maximum_length"`
+				Nullable  string `sql:"is_nullable"`
+				Default   string `sql:"column_default"`
+				Identity  string `sql:"identity_generation"`
+				Generated string `sql:"generation_expression"`
+			}
+			_, err = tx.Query(&infoColumn, `SELECT data_type, udt_name, character_maximum_length, is_nullable,
+			column_default, identity_generation, generation_expression FROM information_schema.columns
+			WHERE table_schema = current_schema() AND table_name = 'test_inspect' ORDER BY ordinal_position`)
+			assert.NoError(err)
+			if assert.Equal(len(infoColumn), len(column)) {
+				for i, col := range infoColumn {
+					assert.Equal(col.DataType, column[i].DataType)
+					assert.Equal(col.UdtName, column[i].UdtName)
+					assert.Equal(col.MaxLen, column[i].CharMaxLen)
+					assert.Equal(col.Nullable == "YES", column[i].Nullable)
+					assert.Equal(col.Default, column[i].Default)
+					assert.Equal(col.Identity, column[i].Identity)
+					assert.Equal(col.Generated, column[i].Generated)
+				}
+				assert.Equal(10, column[2].Precision)
+				assert.Equal(2, column[2].Scale)
+				if assert.NotNil(column[4].TimePrecision) {
+					assert.Equal(3, *column[4].TimePrecision)
+				}
+			}
+		}
+	}
+}
+
+func TestInspectSearchPath(t *testing.T) {
+	assert := assert.New(t)
+	if conn, err := psql.Conn(true); err == nil {
+		var tx *pg.Tx
+		if tx, err = conn.Begin(); err == nil {
+			defer tx.Rollback()
+			_, err = tx.Exec(`CREATE SCHEMA test_inspect_path;
+			CREATE TABLE test_inspect_path.test_path (id int);
+			SET LOCAL search_path TO public, test_inspect_path;`)
+			assert.NoError(err)
+			//table of any schema of search path is matched
+			tables, err := inspect.Tables(tx, "", "test_path")
+			assert.NoError(err)
+			assert.Equal([]string{"test_path"}, tables)
+			tables, err = inspect.Tables(tx, "public", "test_path")
+			assert.NoError(err)
+			assert.Equal(0, len(tables))
+
+			//table hidden by same named table earlier in search path is not matched
+			_, err = tx.Exec(`CREATE TABLE public.test_path (id int, name text)`)
+			assert.NoError(err)
+			column, err := inspect.Columns(tx, "", "test_path")
+			assert.NoError(err)
+			assert.Equal(2, len(column))
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package shifter
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//defaultBackfillBatch is default number of rows updated in a backfill batch
+const defaultBackfillBatch = 1000
+
+//BackfillFunc will fill at most batchSize rows of column which are null
+//and return number of rows updated
+type BackfillFunc func(tx *pg.Tx, tableName, column string, batchSize int) (updated int, err error)
+
+//BackfillProgress is called after each backfill batch
+type BackfillProgress func(tableName, column string, done, total int64)
+
+// BackfillBatch will set number of rows updated in each backfill transaction.
+//
+//default 1000
+func (s *Shifter) BackfillBatch(size int) *Shifter {
+	s.batchSize = size
+	return s
+}
+
+//OnBackfillProgress will set progress callback of backfill. Default progress is printed on console
+func (s *Shifter) OnBackfillProgress(fn BackfillProgress) *Shifter {
+	s.progress = fn
+	return s
+}
+
+//needBackfill will check not null column without default has backfill
+//then column is added as nullable and set not null after backfill
+func (s *Shifter) needBackfill(schema model.ColSchema) (flag bool) {
+	if schema.IsNullable == no && schema.ColumnDefault == "" && isVolatileDefault(schema) == false {
+		_, exists := s.getBackfillFromMethod(schema.TableName)[schema.ColumnName]
+		flag = exists || schema.Backfill != ""
+	}
+	return
+}
+
+//deferBackfill will defer batch backfill of column and set not null after commit
+func (s *Shifter) deferBackfill(tx *pg.Tx, schema model.ColSchema) (err error) {
+	sql := getBackfillSQL(schema.TableName, schema.ColumnName, schema.Backfill, s.getBatchSize())
+	if _, exists := s.getBackfillFromMethod(schema.TableName)[schema.ColumnName]; exists {
+		sql = fmt.Sprintf("-- %v.%v backfill by Backfill() func in batches of %v\n",
+			schema.TableName, schema.ColumnName, s.getBatchSize())
+	}
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opBackfill,
+		Lock: RowExclusiveLock, Scan: true, SQL: sql, backfill: schema.Backfill,
+		run: func(conn *pg.DB) error { return s.runBackfill(conn, schema) }}
+	if err = s.deferStep(tx, step); err == nil && schema.IsNullable == no {
+		err = s.deferSetNotNull(tx, schema)
+	}
+	return
+}
+
+//deferSetNotNull will defer set not null of column after commit
+func (s *Shifter) deferSetNotNull(tx *pg.Tx, schema model.ColSchema) (err error) {
+	if s.online {
+		checkName := getNotNullCheckName(schema.TableName, schema.ColumnName)
+		step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opAddConstraint,
+			SQL: getNotNullCheckSQL(schema, checkName), checks: []dataCheck{getNullCheck(schema)}}
+		if err = s.deferStep(tx, step); err == nil {
+			if err = s.deferValidate(tx, schema, checkName); err == nil {
+				err = s.deferStep(tx, getOnlineNotNullStep(schema, checkName))
+			}
+		}
+	} else {
+		sql := getNotNullColSQL(schema.TableName, schema.ColumnName, set) + ";\n"
+		err = s.deferStep(tx, Step{Table: schema.TableName, Column: schema.ColumnName,
+			Op: opSetNotNull, Scan: true, SQL: sql, checks: []dataCheck{getNullCheck(schema)}})
+	}
+	return
+}
+
+//runBackfill will backfill null values of column in batches, each in its own transaction
+func (s *Shifter) runBackfill(conn *pg.DB, schema model.ColSchema) (err error) {
+	var total, done int64
+	fn, isFunc := s.getBackfillFromMethod(schema.TableName)[schema.ColumnName]
+	sql := getBackfillSQL(schema.TableName, schema.ColumnName, schema.Backfill, s.getBatchSize())
+	count := fmt.Sprintf("SELECT count(*) FROM %v WHERE %v IS NULL", schema.TableName, schema.ColumnName)
+
+	if _, err = conn.Query(pg.Scan(&total), count); err != nil {
+		err = getWrapError(schema.TableName, opBackfill, count, err)
+	}
+	//rows are bounded by initial null count so expression returning null will not loop forever
+	for err == nil && done < total {
+		var updated int
+		if err = s.retryTx(conn, func(tx *pg.Tx) (err error) {
+			if isFunc {
+				updated, err = fn(tx, schema.TableName, schema.ColumnName, s.getBatchSize())
+			} else {
+				var res orm.Result
+				if res, err = tx.Exec(sql); err == nil {
+					updated = res.RowsAffected()
+				}
+			}
+			if err != nil {
+				err = getWrapError(schema.TableName, opBackfill, sql, err)
+			}
+			return
+		}); err == nil {
+			if updated == 0 {
+				break
+			}
+			done += int64(updated)
+			s.backfillProgress(schema.TableName, schema.ColumnName, done, total)
+		}
+	}
+	return
+}
+
+//backfillProgress will report backfill progress
+func (s *Shifter) backfillProgress(tableName, column string, done, total int64) {
+	if s.progress != nil {
+		s.progress(tableName, column, done, total)
+	} else {
+		fmt.Printf("Backfill %v.%v: %v/%v\n", tableName, column, done, total)
+	}
+}
+
+//getBatchSize will return backfill batch size
+func (s *Shifter) getBatchSize() (size int) {
+	if size = s.batchSize; size <= 0 {
+		size = defaultBackfillBatch
+	}
+	return
+}
+
+//getBackfillSQL will return batch update sql of null values of column
+func getBackfillSQL(tName, cName, expr string, batchSize int) (sql string) {
+	sql = fmt.Sprintf("UPDATE %v SET %v = %v WHERE ctid = ANY(ARRAY("+
+		"SELECT ctid FROM %v WHERE %v IS NULL LIMIT %v));\n",
+		tName, cName, expr, tName, cName, batchSize)
+	return
+}
+
+//getBackfillFromMethod will return column backfill from Backfill() method associated to table structure
+func (s *Shifter) getBackfillFromMethod(tableName string) (backfill map[string]BackfillFunc) {
+	if dbModel, exists := s.table[tableName]; exists {
+		refObj := reflect.ValueOf(dbModel)
+		m := refObj.MethodByName("Backfill")
+		if m.IsValid() && m.Type().NumIn() == 0 {
+			out := m.Call([]reflect.Value{})
+			if len(out) > 0 && out[0].Kind() == reflect.Map {
+				backfill, _ = out[0].Interface().(map[string]BackfillFunc)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,276 @@
+package shifter
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
+	"github.com/mayur-tolexo/pg-shifter/model"
+	"github.com/mayur-tolexo/pg-shifter/util"
+)
+
+//catalog is snapshot of columns, constraints, indexes, composite unique keys, triggers and enums
+//of all the tables set in shifter loaded by inspect package in a handful of catalog queries.
+//Table is removed from snapshot once any step is executed on it so it is read from database again
+type catalog struct {
+	loaded bool
+	table  map[string]*tableCatalog
+	enum   map[string][]string
+}
+
+//tableCatalog is snapshot of a table
+type tableCatalog struct {
+	column     []model.ColSchema
+	constraint []model.ColSchema
+	index      []model.Index
+	uk         []model.UKSchema
+	hisExists  bool
+}
+
+//newCatalog will return empty catalog which is loaded on first read
+func newCatalog() *catalog {
+	return &catalog{table: make(map[string]*tableCatalog)}
+}
+
+//invalidate will remove table of executed step from snapshot.
+//Migrations can change any table so whole snapshot is dropped
+func (c *catalog) invalidate(step Step) {
+	if c != nil {
+		switch step.Op {
+		case opRunMigration:
+			c.reset()
+		case opCreateEnum, opAddEnumValue, opDropEnumValue, opDropEnum:
+			c.enum = nil
+			delete(c.table, step.Table)
+		default:
+			delete(c.table, step.Table)
+		}
+	}
+}
+
+//reset will drop the snapshot so everything is read from database.
+//Used after user code like hooks is executed as it can change any table
+func (c *catalog) reset() {
+	if c != nil {
+		c.table, c.enum = make(map[string]*tableCatalog), nil
+	}
+}
+
+//getCatalogTable will return snapshot of table.
+//nil is returned if snapshot is not enabled or table is changed after it was loaded
+func (s *Shifter) getCatalogTable(tx *pg.Tx, tableName string) (tc *tableCatalog, err error) {
+	if s.catalog != nil {
+		if err = s.loadCatalog(tx); err == nil {
+			tc = s.catalog.table[tableName]
+		}
+	}
+	return
+}
+
+//loadCatalog will load snapshot of all the tables set in shifter if not loaded yet
+func (s *Shifter) loadCatalog(tx *pg.Tx) (err error) {
+	if s.catalog.loaded == false {
+		var (
+			column     []inspect.Column
+			constraint []inspect.Constraint
+			index      []inspect.Index
+			trigger    []inspect.Trigger
+			enum       []inspect.Enum
+		)
+		tables := s.getTableNames()
+		if column, err = inspect.Columns(tx, "", tables...); err == nil {
+			if constraint, err = inspect.Constraints(tx, "", tables...); err == nil {
+				if index, err = inspect.Indexes(tx, "", tables...); err == nil {
+					if trigger, err = inspect.Triggers(tx, "", tables...); err == nil {
+						enum, err = inspect.Enums(tx, "")
+					}
+				}
+			}
+		}
+		if err == nil {
+			s.catalog.loaded = true
+			s.catalog.table = groupCatalogTable(tables, column, constraint, index, trigger)
+			s.catalog.enum = make(map[string][]string, len(enum))
+			for _, curEnum := range enum {
+				s.catalog.enum[curEnum.Name] = curEnum.Values
+			}
+		} else {
+			err = getWrapError("catalog", "inspect", "", err)
+		}
+	}
+	return
+}
+
+//groupCatalogTable will group description of all the tables by table
+func groupCatalogTable(tables []string, column []inspect.Column, constraint []inspect.Constraint,
+	index []inspect.Index, trigger []inspect.Trigger) (table map[string]*tableCatalog) {
+
+	tColumn := make(map[string][]inspect.Column)
+	tConstraint := make(map[string][]inspect.Constraint)
+	tIndex := make(map[string][]inspect.Index)
+	for _, col := range column {
+		tColumn[col.Table] = append(tColumn[col.Table], col)
+	}
+	for _, con := range constraint {
+		tConstraint[con.Table] = append(tConstraint[con.Table], con)
+	}
+	for _, idx := range index {
+		tIndex[idx.Table] = append(tIndex[idx.Table], idx)
+	}
+	table = make(map[string]*tableCatalog, len(tables))
+	for _, tableName := range tables {
+		table[tableName] = &tableCatalog{
+			column:     toColumnSchema(tColumn[tableName]),
+			constraint: toConstraintSchema(tConstraint[tableName]),
+			index:      toIndex(tIndex[tableName]),
+			uk:         toUKSchema(tConstraint[tableName]),
+		}
+	}
+	for _, trg := range trigger {
+		if trg.Name == util.GetAfterUpdateTriggerName(trg.Table) && trg.Timing == "AFTER" {
+			table[trg.Table].hisExists = true
+		}
+	}
+	return
+}
+
+//toColumnSchema will return column schema of inspected columns.
+//Soft dropped columns are not included
+func toColumnSchema(column []inspect.Column) (columnSchema []model.ColSchema) {
+	for _, col := range column {
+		if strings.Contains(col.Name, droppedSuffix) {
+			continue
+		}
+		schema := model.ColSchema{ColumnName: col.Name, ColumnDefault: col.Default, DataType: col.DataType,
+			UdtName: col.UdtName, FullType: col.FullType, IsNullable: no, Position: col.Position,
+			SeqName: col.SeqName, SeqDataType: col.SeqDataType, Identity: col.Identity,
+			Generated: col.Generated}
+		if col.Nullable {
+			schema.IsNullable = yes
+		}
+		if col.CharMaxLen > 0 {
+			schema.CharMaxLen = strconv.Itoa(col.CharMaxLen)
+		}
+		if col.Precision > 0 {
+			schema.NumericPrecision, schema.NumericScale = strconv.Itoa(col.Precision), strconv.Itoa(col.Scale)
+		}
+		if col.TimePrecision != nil {
+			schema.DatetimePrecision = strconv.Itoa(*col.TimePrecision)
+		}
+		columnSchema = append(columnSchema, schema)
+	}
+	return
+}
+
+//toConstraintSchema will return single column primary, unique and foreign key constraints.
+//Primary and unique key refer to its own column as in information_schema
+func toConstraintSchema(constraint []inspect.Constraint) (conSchema []model.ColSchema) {
+	for _, con := range constraint {
+		if len(con.Columns) == 1 &&
+			(con.Type == primaryKey || con.Type == uniqueKey || con.Type == foreignKey) {
+			schema := model.ColSchema{ColumnName: con.Columns[0], ConstraintType: con.Type,
+				ConstraintName: con.Name, IsDeferrable: no, InitiallyDeferred: no,
+				ForeignTableName: con.Table, ForeignColumnName: con.Columns[0],
+				UpdateType: con.UpdateRule, DeleteType: con.DeleteRule, NotValid: con.Validated == false}
+			if con.Type == foreignKey && len(con.ForeignColumns) == 1 {
+				schema.ForeignTableName, schema.ForeignColumnName = con.ForeignTable, con.ForeignColumns[0]
+			}
+			if con.Deferrable {
+				schema.IsDeferrable = yes
+			}
+			if con.InitiallyDeferred {
+				schema.InitiallyDeferred = yes
+			}
+			conSchema = append(conSchema, schema)
+		}
+	}
+	return
+}
+
+//toUKSchema will return composite unique keys
+func toUKSchema(constraint []inspect.Constraint) (ukSchema []model.UKSchema) {
+	for _, con := range constraint {
+		if con.Type == uniqueKey && len(con.Columns) > 1 {
+			ukSchema = append(ukSchema, model.UKSchema{ConstraintName: con.Name,
+				Columns: strings.Join(con.Columns, ",")})
+		}
+	}
+	return
+}
+
+//toIndex will return non unique indexes
+func toIndex(index []inspect.Index) (idx []model.Index) {
+	for _, curIdx := range index {
+		if curIdx.Unique == false {
+			idx = append(idx, model.Index{IdxName: curIdx.Name, IType: curIdx.Method,
+				Columns: strings.Join(curIdx.Columns, ",")})
+		}
+	}
+	return
+}
+
+//getTableUK will return composite unique keys of table from snapshot or database
+func (s *Shifter) getTableUK(tx *pg.Tx, tableName string) (ukSchema []model.UKSchema, err error) {
+	var tc *tableCatalog
+	if tc, err = s.getCatalogTable(tx, tableName); err == nil {
+		if tc != nil {
+			ukSchema = tc.uk
+		} else {
+			ukSchema, err = getDBCompositeUniqueKey(tx, tableName)
+		}
+	}
+	return
+}
+
+//getTableIndex will return indexes of table from snapshot or database
+func (s *Shifter) getTableIndex(tx *pg.Tx, tableName string) (idx []model.Index, err error) {
+	var tc *tableCatalog
+	if tc, err = s.getCatalogTable(tx, tableName); err == nil {
+		if tc != nil {
+			idx = tc.index
+		} else {
+			idx, err = getDBIndex(tx, tableName)
+		}
+	}
+	return
+}
+
+//hasAfterUpdateTrigger will check after update history trigger of table exists from snapshot or database
+func (s *Shifter) hasAfterUpdateTrigger(tx *pg.Tx, tableName string) (exists bool, err error) {
+	var tc *tableCatalog
+	if tc, err = s.getCatalogTable(tx, tableName); err == nil {
+		if tc != nil {
+			exists = tc.hisExists
+		} else {
+			exists, err = util.IsAfterUpdateTriggerExists(tx, tableName)
+		}
+	}
+	return
+}
+
+//getEnumValue will return enum values from snapshot or database
+func (s *Shifter) getEnumValue(tx *pg.Tx, enumName string) (enumValue []string, err error) {
+	var cached bool
+	if s.catalog != nil {
+		if err = s.loadCatalog(tx); err == nil && s.catalog.enum != nil {
+			enumValue, cached = s.catalog.enum[enumName]
+		}
+	}
+	if err == nil && cached == false {
+		enumValue, err = getDBEnumValue(tx, enumName)
+	}
+	return
+}
+
+//enumExists will check enum exists from snapshot or database.
+//Types which are not enum are checked in database
+func (s *Shifter) enumExists(tx *pg.Tx, enumName string) (flag bool) {
+	if s.catalog != nil && s.loadCatalog(tx) == nil && s.catalog.enum != nil {
+		_, flag = s.catalog.enum[enumName]
+	}
+	if flag == false {
+		flag = dbEnumExists(tx, enumName)
+	}
+	return
+}
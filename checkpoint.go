@@ -0,0 +1,348 @@
+package shifter
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//checkpointTable keeps the deferred steps which are not executed yet
+const checkpointTable = "shifter_checkpoint"
+
+//StatementError is returned when a statement of step failed.
+//Statements before it in the same transaction are rolled back with it
+type StatementError struct {
+	Step      Step
+	Index     int //position of failed statement starting from 1
+	Total     int
+	Statement string
+	Err       error
+}
+
+//Error will return failed statement with its position in step
+func (e *StatementError) Error() string {
+	return fmt.Sprintf("%v %v failed at statement %v/%v: %v\nStatement: %v",
+		e.Step.Table, e.Step.Op, e.Index, e.Total, e.Err.Error(), e.Statement)
+}
+
+//checkpoint is pending deferred step or failed step of migration transaction recorded in checkpoint table
+type checkpoint struct {
+	ID         int    `sql:"id"`
+	TableName  string `sql:"table_name"`
+	ColumnName string `sql:"column_name"`
+	Op         string `sql:"op"`
+	Lock       string `sql:"lock"`
+	Scan       bool   `sql:"scan"`
+	Backfill   string `sql:"backfill"`
+	SQL        string `sql:"sql"`
+	Deferred   bool   `sql:"deferred"`
+}
+
+// Resume will execute the deferred steps of a failed run from the failing step.
+//
+// Only steps deferred after commit (online validation, backfill and set not null) are recorded in
+// shifter_checkpoint table in the migration transaction and removed once executed.
+// Steps inside the migration transaction are rolled back together on failure so they can't be resumed,
+// their failure is recorded and Resume returns error till they are applied by rerun of alter.
+// AlterTable and AlterAllTable resume the pending steps before altering with their prompt choice.
+// To resume a backfill by Backfill() method the table model should be set in shifter.
+//
+// Parameters
+//  conn: postgresql connection
+//  skipPrompt: bool (default false | if false then before executing pending step it will prompt for confirmation)
+func (s *Shifter) Resume(conn *pg.DB, skipPrompt ...bool) (err error) {
+	err = s.resume(conn, getSP(skipPrompt), false)
+	return
+}
+
+//resume will execute pending deferred steps.
+//On rerun failed steps of migration transaction are cleared as rerun applies them
+//else error is returned for them
+func (s *Shifter) resume(conn *pg.DB, skipPrompt, rerun bool) (err error) {
+	err = s.withAdvisoryLock(conn, func() (err error) {
+		var pending, failed []Step
+		if pending, failed, err = s.getPendingStep(conn); err == nil && len(pending) > 0 {
+			fmt.Printf("Resuming %v pending step\n", len(pending))
+			s.deferred = pending
+			err = s.runDeferred(conn, skipPrompt)
+		}
+		if err == nil && len(failed) > 0 {
+			if rerun {
+				err = s.retryTx(conn, func(tx *pg.Tx) (err error) {
+					for _, step := range failed {
+						if err = doneCheckpoint(tx, step); err != nil {
+							break
+						}
+					}
+					return
+				})
+			} else {
+				err = getFailedStepError(failed)
+			}
+		}
+		return
+	})
+	return
+}
+
+//getFailedStepError will return error of steps failed in migration transaction
+func getFailedStepError(failed []Step) error {
+	msg := fmt.Sprintf("%v step failed in migration transaction which was rolled back, "+
+		"rerun alter to apply it:", len(failed))
+	for _, step := range failed {
+		msg += fmt.Sprintf("\n%v %v %v", step.Table, step.Column, step.Op)
+	}
+	return errors.New(msg)
+}
+
+//execStep will execute statements of step one by one
+//so the failed statement can be reported
+func execStep(tx *pg.Tx, step Step) (err error) {
+	stmt := splitStatement(step.SQL)
+	for i, curStmt := range stmt {
+		if _, err = tx.Exec(curStmt); err != nil {
+			err = &StatementError{Step: step, Index: i + 1, Total: len(stmt), Statement: curStmt, Err: err}
+			break
+		}
+	}
+	return
+}
+
+//saveCheckpoint will record deferred steps in checkpoint table in the migration transaction
+//so they can be resumed if the run fails after commit
+func (s *Shifter) saveCheckpoint(tx *pg.Tx) (err error) {
+	if len(s.deferred) > 0 && s.plan == false {
+		if err = createCheckpointTable(tx); err == nil {
+			sql := fmt.Sprintf("INSERT INTO %v (table_name, column_name, op, lock, scan, backfill, sql) "+
+				"VALUES (?, ?, ?, ?, ?, ?, ?) RETURNING id;", checkpointTable)
+			for i, step := range s.deferred {
+				if _, err = tx.QueryOne(pg.Scan(&s.deferred[i].checkpoint), sql, step.Table, step.Column,
+					step.Op, step.Lock, step.Scan, step.backfill, step.SQL); err != nil {
+					err = getWrapError(step.Table, "save checkpoint", sql, err)
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+//saveFailedStep will record steps failed in migration transaction which is rolled back
+//so Resume doesn't report success without applying them.
+//Failed deferred steps are already in checkpoint table
+func (s *Shifter) saveFailedStep(conn *pg.DB, runErr error) {
+	var failed []Step
+	if allErr, ok := runErr.(*AllTableError); ok {
+		for _, f := range allErr.Failures {
+			if sErr := getStatementError(f.Err); sErr != nil {
+				failed = append(failed, sErr.Step)
+			}
+		}
+	} else if sErr := getStatementError(runErr); sErr != nil {
+		failed = append(failed, sErr.Step)
+	}
+	if len(failed) > 0 && s.plan == false {
+		var (
+			tx  *pg.Tx
+			err error
+		)
+		if tx, err = conn.Begin(); err == nil {
+			if err = createCheckpointTable(tx); err == nil {
+				sql := fmt.Sprintf("INSERT INTO %v (table_name, column_name, op, lock, scan, sql, deferred) "+
+					"VALUES (?, ?, ?, ?, ?, ?, false);", checkpointTable)
+				for _, step := range failed {
+					if step.checkpoint == 0 {
+						if _, err = tx.Exec(sql, step.Table, step.Column, step.Op, step.Lock,
+							step.Scan, step.SQL); err != nil {
+							break
+						}
+					}
+				}
+			}
+			commitIfNil(tx, err)
+		}
+		if err != nil {
+			fmt.Println("Failed step not recorded in checkpoint:", err)
+		}
+	}
+}
+
+//getStatementError will return failed statement of step from wrapped error
+func getStatementError(err error) (sErr *StatementError) {
+	for unwrap := true; unwrap; {
+		switch wErr := err.(type) {
+		case *wrapError:
+			err = wErr.err
+		case *StatementError:
+			sErr, unwrap = wErr, false
+		default:
+			unwrap = false
+		}
+	}
+	return
+}
+
+//doneCheckpoint will remove executed step from checkpoint table
+func doneCheckpoint(tx *pg.Tx, step Step) (err error) {
+	if step.checkpoint > 0 {
+		sql := fmt.Sprintf("DELETE FROM %v WHERE id = ?;", checkpointTable)
+		if _, err = tx.Exec(sql, step.checkpoint); err != nil {
+			err = getWrapError(step.Table, "done checkpoint", sql, err)
+		}
+	}
+	return
+}
+
+//getPendingStep will return pending deferred steps and failed steps of migration transaction
+//from checkpoint table in order
+func (s *Shifter) getPendingStep(conn *pg.DB) (pending, failed []Step, err error) {
+	var (
+		num         int
+		checkpoints []checkpoint
+	)
+	sql := `SELECT 1 FROM pg_tables WHERE tablename = ?;`
+	if _, err = conn.Query(pg.Scan(&num), sql, checkpointTable); err == nil && num == 1 {
+		sql = fmt.Sprintf("SELECT id, table_name, column_name, op, lock, scan, backfill, sql, deferred "+
+			"FROM %v ORDER BY id;", checkpointTable)
+		if _, err = conn.Query(&checkpoints, sql); err == nil {
+			for _, cp := range checkpoints {
+				var step Step
+				if cp.Deferred == false {
+					failed = append(failed, Step{Table: cp.TableName, Column: cp.ColumnName, Op: cp.Op,
+						SQL: cp.SQL, checkpoint: cp.ID})
+				} else if step, err = s.getCheckpointStep(cp); err != nil {
+					break
+				} else {
+					pending = append(pending, step)
+				}
+			}
+		}
+	}
+	if err != nil {
+		err = getWrapError(checkpointTable, "pending step", sql, err)
+	}
+	return
+}
+
+//getCheckpointStep will return step of checkpoint.
+//Backfill step is rebuilt from the table model
+func (s *Shifter) getCheckpointStep(cp checkpoint) (step Step, err error) {
+	step = Step{Table: cp.TableName, Column: cp.ColumnName, Op: cp.Op, Lock: cp.Lock,
+		Scan: cp.Scan, SQL: cp.SQL, backfill: cp.Backfill, checkpoint: cp.ID}
+	if cp.Op == opBackfill {
+		schema := model.ColSchema{TableName: cp.TableName, ColumnName: cp.ColumnName, Backfill: cp.Backfill}
+		if _, isFunc := s.getBackfillFromMethod(cp.TableName)[cp.ColumnName]; isFunc == false && cp.Backfill == "" {
+			err = errors.New("Set table model to resume backfill of " + cp.TableName + "." + cp.ColumnName)
+		} else {
+			step.run = func(conn *pg.DB) error { return s.runBackfill(conn, schema) }
+		}
+	}
+	return
+}
+
+//createCheckpointTable will create checkpoint table if not exists
+func createCheckpointTable(tx *pg.Tx) (err error) {
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+		id BIGSERIAL PRIMARY KEY,
+		table_name VARCHAR(63) NOT NULL,
+		column_name VARCHAR(63),
+		op VARCHAR(50) NOT NULL,
+		lock VARCHAR(50),
+		scan BOOLEAN NOT NULL DEFAULT false,
+		backfill TEXT,
+		sql TEXT NOT NULL,
+		deferred BOOLEAN NOT NULL DEFAULT true,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`, checkpointTable)
+	if _, err = tx.Exec(sql); err != nil {
+		err = getWrapError(checkpointTable, "create table", sql, err)
+	}
+	return
+}
+
+//splitStatement will split sql into statements by semicolon.
+//Semicolon inside quotes, dollar quotes and comments is not considered.
+//Statements having only comments are skipped
+func splitStatement(sql string) (stmt []string) {
+	var (
+		cur       strings.Builder
+		quote     byte
+		dollarTag string
+		hasCode   bool
+	)
+	add := func() {
+		if hasCode {
+			stmt = append(stmt, strings.TrimSpace(cur.String()))
+		}
+		cur.Reset()
+		hasCode = false
+	}
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case dollarTag != "":
+			if strings.HasPrefix(sql[i:], dollarTag) {
+				cur.WriteString(dollarTag)
+				i += len(dollarTag) - 1
+				dollarTag = ""
+				continue
+			}
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote, hasCode = c, true
+		case c == '$':
+			if end := strings.IndexByte(sql[i+1:], '$'); end >= 0 && isDollarTag(sql[i+1:i+1+end]) {
+				dollarTag, hasCode = sql[i:i+end+2], true
+				cur.WriteString(dollarTag)
+				i += len(dollarTag) - 1
+				continue
+			}
+			hasCode = true
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end < 0 {
+				end = len(sql) - i
+			}
+			cur.WriteString(sql[i : i+end])
+			i += end - 1
+			continue
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i:], "*/")
+			if end < 0 {
+				end = len(sql) - i
+			} else {
+				end += 2
+			}
+			cur.WriteString(sql[i : i+end])
+			i += end - 1
+			continue
+		case c == ';':
+			cur.WriteByte(c)
+			add()
+			continue
+		case c != ' ' && c != '\t' && c != '\n' && c != '\r':
+			hasCode = true
+		}
+		cur.WriteByte(c)
+	}
+	add()
+	return
+}
+
+//isDollarTag will check tag between dollars is a valid dollar quote tag
+func isDollarTag(tag string) (flag bool) {
+	flag = true
+	for i, c := range tag {
+		if c != '_' && (c < 'a' || c > 'z') && (c < 'A' || c > 'Z') && (i == 0 || c < '0' || c > '9') {
+			flag = false
+			break
+		}
+	}
+	return
+}
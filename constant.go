@@ -23,6 +23,7 @@ const (
 	drop                = "DROP"
 	set                 = "SET"
 	userDefined         = "USER-DEFINED"
+	arrayType           = "ARRAY"
 	deferrable          = "DEFERRABLE"
 	initiallyDeferred   = "INITIALLY DEFERRED"
 	initiallyImmediate  = "INITIALLY IMMEDIATE"
@@ -39,11 +40,50 @@ const (
 	primaryKeySuffix    = "pkey"
 	uniqueKeySuffix     = "key"
 	foreignKeySuffix    = "fkey"
-	TriggerTag          = "trigger" //use to create triggers on table.
-	HistoryTag          = "history" //use to create history table. Default table_history if after trigger given
+	notNullSuffix       = "not_null"
+	droppedSuffix       = "__dropped_"
+	TriggerTag          = "trigger"    //use to create triggers on table.
+	HistoryTag          = "history"    //use to create history table. Default table_history if after trigger given
+	BackfillTag         = "backfill"   //use to backfill not null column while adding it. Value is sql expression
+	UsingTag            = "using"      //use to convert column value on data type change. Value is sql expression
+	UsingFromTag        = "using_from" //type from which using expression converts the column
+	MigrateFromTag      = "from"       //use to expand new column from old column which is dropped on contract
 	afterInsertTrigger  = "ai"
 	afterUpdateTrigger  = "au"
 	afterDeleteTrigger  = "ad"
 	beforeUpdateTrigger = "bu"
 	curPkg              = "shifter \"github.com/mayur-tolexo/pg-shifter\""
 )
+
+//step operations
+const (
+	opAddColumn          = "add column"
+	opDropColumn         = "drop column"
+	opModifyDataType     = "modify datatype"
+	opModifyDefault      = "modify default"
+	opSetNotNull         = "set not null"
+	opDropNotNull        = "drop not null"
+	opAddConstraint      = "add constraint"
+	opDropConstraint     = "drop constraint"
+	opModifyDeferrable   = "modify deferrable"
+	opAddCompositeUK     = "add composite unique key"
+	opDropCompositeUK    = "drop composite unique key"
+	opAddEnumValue       = "add enum value"
+	opDropEnumValue      = "drop enum value"
+	opDropEnum           = "drop enum"
+	opDropTable          = "drop table"
+	opCreateEnum         = "create enum"
+	opCreateTrigger      = "create trigger"
+	opValidateConstraint = "validate constraint"
+	opBackfill           = "backfill"
+	opCreateSyncTrigger  = "create sync trigger"
+	opDropSyncTrigger    = "drop sync trigger"
+	opSoftDropColumn     = "soft drop column"
+	opSoftDropTable      = "soft drop table"
+	opRestoreColumn      = "restore column"
+	opPurgeColumn        = "purge column"
+	opPurgeTable         = "purge table"
+	opRunMigration       = "run migration"
+	opModifyIdentity     = "modify identity"
+	opModifyGenerated    = "modify generated"
+)
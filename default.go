@@ -0,0 +1,141 @@
+package shifter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//defaultSavepoint is savepoint in which constant defaults are compared by value
+const defaultSavepoint = "shifter_default"
+
+var (
+	//literalCastRegex matches cast of a quoted literal which pg_get_expr adds like 'a'::text
+	literalCastRegex = regexp.MustCompile(`('(?:[^']|'')*')::[a-z_][a-z0-9_.]*(?:\([0-9,]*\))?(?:\[\])*`)
+	//endCastRegex matches casts at the end of expression
+	endCastRegex = regexp.MustCompile(`(?:::[a-z_][a-z0-9_.]*(?:\([0-9,]*\))?(?:\[\])*)+$`)
+	//valueFuncRegex matches sql value functions which are not constant
+	valueFuncRegex = regexp.MustCompile(`(^|[^a-z0-9_])(current_[a-z_]+|localtime[a-z_]*|session_user|user)($|[^a-z0-9_])`)
+	//exprAlias are the forms stored by different postgresql versions for same expression
+	exprAlias = strings.NewReplacer(
+		"transaction_timestamp()", "current_timestamp",
+		"now()", "current_timestamp",
+		"('now'::text)::date", "current_date",
+		"('now'::text)::timestamp", "localtimestamp",
+	)
+)
+
+//isSameColDefault will check table and struct default values are same.
+//It is used by alter, plan and verify so it doesn't run any ddl and works in read only transaction.
+//If they differ by text and both are constants then their values cast to the column type are compared,
+//else the defaults are compared by text normalized by normalizeExpr
+func (s *Shifter) isSameColDefault(tx *pg.Tx, tSchema, sSchema model.ColSchema) (isSame bool, err error) {
+	if isSame = isSameDefault(tSchema, sSchema); isSame == false &&
+		tSchema.ColumnDefault != "" && sSchema.ColumnDefault != "" && sSchema.DefaultExists &&
+		tSchema.ConstraintType != primaryKey && tSchema.SeqName == "" {
+
+		if isConstDefault(tSchema.ColumnDefault) && isConstDefault(sSchema.ColumnDefault) {
+			isSame, err = isSameConstDefault(tx, tSchema, sSchema)
+		} else {
+			isSame = normalizeExpr(tSchema.ColumnDefault) == normalizeExpr(sSchema.ColumnDefault)
+		}
+	}
+	return
+}
+
+//isSameConstDefault will compare constant defaults cast to the column type as text.
+//Comparison is done inside a savepoint so invalid cast is reported as changed without aborting transaction
+func isSameConstDefault(tx *pg.Tx, tSchema, sSchema model.ColSchema) (isSame bool, err error) {
+	if _, err = tx.Exec("SAVEPOINT " + defaultSavepoint); err == nil {
+		var same []bool
+		dType := getColumnType(sSchema)
+		sql := fmt.Sprintf("SELECT ((%v)::%v)::text = ((%v)::%v)::text",
+			tSchema.ColumnDefault, dType, sSchema.ColumnDefault, dType)
+		if _, cErr := tx.Query(&same, sql); cErr == nil && len(same) > 0 {
+			isSame = same[0]
+		}
+		if _, err = tx.Exec("ROLLBACK TO SAVEPOINT " + defaultSavepoint); err == nil {
+			_, err = tx.Exec("RELEASE SAVEPOINT " + defaultSavepoint)
+		}
+	}
+	if err != nil {
+		err = getWrapError(sSchema.TableName, "compare default", sSchema.ColumnDefault, err)
+	}
+	return
+}
+
+//isConstDefault will check default has no function call, sql value function
+//or statement end outside quotes so it can be evaluated without any side effect
+func isConstDefault(def string) (flag bool) {
+	flag = true
+	quote := false
+	unquoted := ""
+	for i := 0; i < len(def) && flag; i++ {
+		switch c := def[i]; {
+		case c == '\'':
+			quote = !quote
+		case quote:
+		case c == '(' || c == ';':
+			flag = false
+		default:
+			unquoted += string(c)
+		}
+	}
+	if flag {
+		flag = valueFuncRegex.MatchString(strings.ToLower(unquoted)) == false
+	}
+	return
+}
+
+//normalizeExpr will return expression in comparable form.
+//Text outside quotes is lower cased without whitespace, casts of quoted literals and at the end,
+//and outer parenthesis are removed and the forms stored by different postgresql versions are aliased
+func normalizeExpr(expr string) (norm string) {
+	quote := false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\'':
+			quote = !quote
+			norm += string(c)
+		case quote:
+			norm += string(c)
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			norm += strings.ToLower(string(c))
+		}
+	}
+	norm = exprAlias.Replace(norm)
+	norm = literalCastRegex.ReplaceAllString(norm, "$1")
+	for prev := ""; prev != norm; {
+		prev = norm
+		norm = endCastRegex.ReplaceAllString(norm, "")
+		if isWrapped(norm) {
+			norm = norm[1 : len(norm)-1]
+		}
+	}
+	return
+}
+
+//isWrapped will check expression is enclosed in a single pair of parenthesis
+func isWrapped(expr string) (flag bool) {
+	if strings.HasPrefix(expr, "(") && strings.HasSuffix(expr, ")") {
+		flag = true
+		depth, quote := 0, false
+		for i := 0; i < len(expr)-1 && flag; i++ {
+			switch c := expr[i]; {
+			case c == '\'':
+				quote = !quote
+			case quote:
+			case c == '(':
+				depth++
+			case c == ')':
+				depth--
+			}
+			flag = depth > 0
+		}
+	}
+	return
+}
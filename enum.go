@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
 	"github.com/mayur-tolexo/pg-shifter/util"
 )
 
@@ -41,7 +42,7 @@ func (s *Shifter) dropAllEnum(tx *pg.Tx, tableName string, skipPrompt bool) (
 		// fmt.Println(enm, enm[fType])
 		if s.isEnum(tableName, fType) {
 			// fmt.Println("IN for ", fType)
-			if _, err = dropEnum(tx, tableName, fType, skipPrompt); err != nil {
+			if _, err = s.dropEnum(tx, tableName, fType, skipPrompt); err != nil {
 				break
 			}
 		}
@@ -56,7 +57,7 @@ func (s *Shifter) upsertEnum(tx *pg.Tx, tableName string,
 	var sEnumValue []string
 	if sEnumValue, err = s.getEnum(tableName, enumName); err == nil {
 		if _, created := enumCreated[enumName]; created == false {
-			if enumSQL, enumExists := getEnumQuery(tx, enumName, sEnumValue); enumExists == false {
+			if enumSQL, enumExists := s.getEnumQuery(tx, enumName, sEnumValue); enumExists == false {
 				err = s.createEnum(tx, tableName, enumName, enumSQL)
 			} else {
 				err = s.updateEnum(tx, tableName, enumName, sEnumValue)
@@ -72,7 +73,7 @@ func (s *Shifter) createEnumByName(tx *pg.Tx, tableName, enumName string) (err e
 	var enumValue []string
 	if enumValue, err = s.getEnum(tableName, enumName); err == nil {
 		if _, created := enumCreated[enumName]; created == false {
-			if enumSQL, enumExists := getEnumQuery(tx, enumName, enumValue); enumExists == false {
+			if enumSQL, enumExists := s.getEnumQuery(tx, enumName, enumValue); enumExists == false {
 				err = s.createEnum(tx, tableName, enumName, enumSQL)
 			}
 		}
@@ -82,11 +83,13 @@ func (s *Shifter) createEnumByName(tx *pg.Tx, tableName, enumName string) (err e
 
 //createEnum will create enum
 func (s *Shifter) createEnum(tx *pg.Tx, tableName, enumName, enumSQL string) (err error) {
-	if _, err = tx.Exec(enumSQL); err == nil {
+	var isAlter bool
+	step := Step{Table: tableName, Op: opCreateEnum, SQL: enumSQL}
+	if isAlter, err = s.execByChoice(tx, step, true); err != nil {
+		err = getWrapError(tableName, opCreateEnum, enumSQL, err)
+	} else if isAlter && s.plan == false {
 		enumCreated[enumName] = struct{}{}
 		fmt.Printf("Enum %v created\n", enumName)
-	} else {
-		err = getWrapError(tableName, "create enum", enumSQL, err)
 	}
 	return
 }
@@ -96,12 +99,12 @@ func (s *Shifter) updateEnum(tx *pg.Tx, tableName,
 	enumName string, sEnumValue []string) (err error) {
 
 	var tEnumValue []string
-	if tEnumValue, err = getDBEnumValue(tx, enumName); err == nil {
+	if tEnumValue, err = s.getEnumValue(tx, enumName); err == nil {
 
-		if _, err = addRemoveEnum(tx, tableName, enumName,
+		if _, err = s.addRemoveEnum(tx, tableName, enumName,
 			sEnumValue, tEnumValue, add); err == nil {
 
-			// _, err = addRemoveEnum(tx, tableName, enumName,
+			// _, err = s.addRemoveEnum(tx, tableName, enumName,
 			// 	tEnumValue, sEnumValue, drop)
 		}
 	}
@@ -109,7 +112,7 @@ func (s *Shifter) updateEnum(tx *pg.Tx, tableName,
 }
 
 //addRemoveEnum will add or remove enum which exists in a but not in b
-func addRemoveEnum(tx *pg.Tx, tableName, enumName string,
+func (s *Shifter) addRemoveEnum(tx *pg.Tx, tableName, enumName string,
 	a, b []string, op string) (isAlter bool, err error) {
 
 	var enumValueMap = make(map[string]struct{})
@@ -122,9 +125,9 @@ func addRemoveEnum(tx *pg.Tx, tableName, enumName string,
 		if _, exists := enumValueMap[curEnumVal]; exists == false {
 			switch op {
 			case add:
-				curIsAlter, err = addEnumVal(tx, tableName, enumName, curEnumVal)
+				curIsAlter, err = s.addEnumVal(tx, tableName, enumName, curEnumVal)
 			case drop:
-				curIsAlter, err = dropEnumVal(tx, tableName, enumName, curEnumVal)
+				curIsAlter, err = s.dropEnumVal(tx, tableName, enumName, curEnumVal)
 			}
 			if err != nil {
 				break
@@ -136,36 +139,39 @@ func addRemoveEnum(tx *pg.Tx, tableName, enumName string,
 }
 
 //addEnumVal will add enum value
-func addEnumVal(tx *pg.Tx, tableName, enumName string, value string) (
+func (s *Shifter) addEnumVal(tx *pg.Tx, tableName, enumName string, value string) (
 	isAlter bool, err error) {
 
 	sql := getEnumAddValSQL(enumName, value)
-	if isAlter, err = execByChoice(tx, sql, false); err != nil {
-		err = getWrapError(tableName, "add enum value", sql, err)
+	step := Step{Table: tableName, Op: opAddEnumValue, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, false); err != nil {
+		err = getWrapError(tableName, opAddEnumValue, sql, err)
 	}
 
 	return
 }
 
 //dropEnumVal will drop enum value
-func dropEnumVal(tx *pg.Tx, tableName, enumName string, value string) (
+func (s *Shifter) dropEnumVal(tx *pg.Tx, tableName, enumName string, value string) (
 	isAlter bool, err error) {
 
 	sql := getEnumDropValSQL(enumName, value)
-	if isAlter, err = execByChoice(tx, sql, false); err != nil {
-		err = getWrapError(tableName, "drop enum value", sql, err)
+	step := Step{Table: tableName, Op: opDropEnumValue, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, false); err != nil {
+		err = getWrapError(tableName, opDropEnumValue, sql, err)
 	}
 
 	return
 }
 
 //dropEnum will drop enum
-func dropEnum(tx *pg.Tx, tableName, enumName string, skipPrompt bool) (
+func (s *Shifter) dropEnum(tx *pg.Tx, tableName, enumName string, skipPrompt bool) (
 	isAlter bool, err error) {
 
 	sql := fmt.Sprintf("DROP TYPE IF EXISTS %v;", enumName)
-	if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-		err = getWrapError(tableName, "drop enum", sql, err)
+	step := Step{Table: tableName, Op: opDropEnum, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(tableName, opDropEnum, sql, err)
 	} else if isAlter {
 		fmt.Printf("Enum Dropped if exists: %v\n", enumName)
 	}
@@ -186,10 +192,10 @@ func getEnumDropValSQL(enumName string, value string) (sql string) {
 }
 
 //Create Enum Query for given table
-func getEnumQuery(tx *pg.Tx, enumName string, enumValue []string) (
+func (s *Shifter) getEnumQuery(tx *pg.Tx, enumName string, enumValue []string) (
 	query string, enumExists bool) {
 
-	if enumExists = dbEnumExists(tx, enumName); enumExists == false {
+	if enumExists = s.enumExists(tx, enumName); enumExists == false {
 		query += fmt.Sprintf("CREATE type %v AS ENUM('%v'); ",
 			enumName, strings.Join(enumValue, "','"))
 	}
@@ -254,12 +260,13 @@ func (s *Shifter) getEnumFromMethod(tableName string) (enum map[string][]string)
 
 //getDBEnumValue enum values by enumType from database
 func getDBEnumValue(tx *pg.Tx, enumName string) (enumValue []string, err error) {
-	query := `SELECT e.enumlabel as enum_value
-	  FROM pg_enum e
-	  JOIN pg_type t ON e.enumtypid = t.oid
-	  WHERE t.typname = ?;`
-	if _, err = tx.Query(&enumValue, query, enumName); err != nil {
-		err = getWrapError(enumName, "enum type", query, err)
+	var enum []inspect.Enum
+	if enum, err = inspect.Enums(tx, "", enumName); err == nil {
+		if len(enum) > 0 {
+			enumValue = enum[0].Values
+		}
+	} else {
+		err = getWrapError(enumName, "enum type", "", err)
 	}
 	return
 }
@@ -0,0 +1,120 @@
+package shifter
+
+import (
+	"errors"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//removeExpandColumn will remove old columns of expanding columns from table schema
+//so they are not dropped till contract. If old column doesn't exist in table
+//then struct column is not expanding anymore
+func removeExpandColumn(tSchema, sSchema map[string]model.ColSchema) {
+	for col, schema := range sSchema {
+		if schema.MigrateFrom != "" {
+			if _, exists := tSchema[schema.MigrateFrom]; exists {
+				delete(tSchema, schema.MigrateFrom)
+			} else {
+				schema.MigrateFrom = ""
+				sSchema[col] = schema
+			}
+		}
+	}
+}
+
+//getExpandExpr will return expression to compute expanding column from old column.
+//Using tag is used if given else old column value
+func (s *Shifter) getExpandExpr(schema model.ColSchema) (expr string) {
+	if expr = schema.Using; expr == "" {
+		expr = schema.MigrateFrom
+	}
+	return
+}
+
+//expandCol will create sync trigger of expanding column
+//and defer backfill of existing rows from old column
+func (s *Shifter) expandCol(tx *pg.Tx, schema model.ColSchema, skipPrompt bool) (isAlter bool, err error) {
+	expr := s.getExpandExpr(schema)
+	sql := getSyncTrigger(schema, expr)
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opCreateSyncTrigger, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(schema.TableName, opCreateSyncTrigger, sql, err)
+	} else if isAlter {
+		schema.Backfill = expr
+		err = s.deferBackfill(tx, schema)
+	}
+	return
+}
+
+// ContractTable will finish the expand/contract migration of table.
+// For each column having from tag whose old column still exists sync trigger is dropped
+// and old column is dropped. Call it once all the readers and writers use the new column.
+//
+// Parameters
+//  conn: postgresql connection
+//  model: struct pointer or string (table name)
+//  skipPrompt: bool (default false | if false then before execution sql it will prompt for confirmation)
+func (s *Shifter) ContractTable(conn *pg.DB, model interface{}, skipPrompt ...bool) (err error) {
+	var tableName string
+	if tableName, err = s.getTableName(model); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
+			err = s.contractTable(tx, tableName, getSP(skipPrompt))
+			return
+		})
+	}
+	return
+}
+
+//ContractAllTable will finish the expand/contract migration of all tables
+//before calling it you need to set the table model in shifter using SetTableModels()
+func (s *Shifter) ContractAllTable(conn *pg.DB, skipPrompt ...bool) (err error) {
+	err = s.runAllTx(conn, AllOrNothing, func(tx *pg.Tx, tableName string) (err error) {
+		err = s.contractTable(tx, tableName, getSP(skipPrompt))
+		return
+	})
+	return
+}
+
+//contractTable will drop sync trigger and old column of expanded columns.
+//Ignored table and columns are not contracted
+func (s *Shifter) contractTable(tx *pg.Tx, tableName string, skipPrompt bool) (err error) {
+	var tSchema map[string]model.ColSchema
+	if _, isValid := s.table[tableName]; isValid == false {
+		err = errors.New("Invalid Table Name: " + tableName)
+	} else if s.isIgnoredTable(tableName) {
+		return
+	} else if tSchema, err = s.getTableSchema(tx, tableName); err == nil {
+		if s.hisExists, err = s.hasAfterUpdateTrigger(tx, tableName); err == nil {
+			tSchema = s.removeIgnoredColumn(tableName, tSchema)
+			sSchema := s.removeIgnoredColumn(tableName, s.GetStructSchema(tableName))
+			for _, col := range getSortedColumn(nil, sSchema) {
+				schema := sSchema[col]
+				if oldSchema, exists := tSchema[schema.MigrateFrom]; exists && schema.MigrateFrom != "" {
+					if err = s.contractCol(tx, tSchema[col], schema, oldSchema, skipPrompt); err != nil {
+						break
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+//contractCol will drop sync trigger and old column of expanded column
+//and set default of the new column which is not set while expanding
+func (s *Shifter) contractCol(tx *pg.Tx, tSchema, schema, oldSchema model.ColSchema,
+	skipPrompt bool) (err error) {
+
+	var isAlter bool
+	sql := getDropSyncTrigger(schema.TableName, schema.ColumnName)
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opDropSyncTrigger, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(schema.TableName, opDropSyncTrigger, sql, err)
+	} else if isAlter || s.plan {
+		if _, err = s.dropCol(tx, oldSchema, skipPrompt); err == nil {
+			_, err = s.modifyDefault(tx, tSchema, schema, skipPrompt)
+		}
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package shifter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+
+	"github.com/go-pg/pg"
+)
+
+//fingerprintTable keeps fingerprint of models after successful alter
+const fingerprintTable = "shifter_fingerprint"
+
+//fingerprintVersion is changed when fingerprint content or alter behaviour changes
+//so the models are compared again after upgrade
+const fingerprintVersion = 1
+
+// SkipUnchanged will skip AlterAllTable when models are not changed since last successful alter.
+//
+// Fingerprint of all the table models (columns, types, tags, Index(), UniqueKey(), Enum(),
+// triggers and other model methods) and alter options (Online, SoftDrop, GuardDestructive, SerialToIdentity) is stored
+// in shifter_fingerprint table after successful AlterAllTable in which no step is declined in prompt.
+// Changes done directly in database are not detected, use Force(true) to compare anyway.
+func (s *Shifter) SkipUnchanged(enable bool) *Shifter {
+	s.skipUnchanged = enable
+	return s
+}
+
+//Force will compare and alter all tables even if models are not changed since last successful alter
+func (s *Shifter) Force(enable bool) *Shifter {
+	s.force = enable
+	return s
+}
+
+//isUnchanged will check stored fingerprint of the model set is same as current fingerprint
+func (s *Shifter) isUnchanged(conn *pg.DB, fingerprint string) (flag bool, err error) {
+	if s.skipUnchanged && s.force == false {
+		var (
+			num    int
+			stored string
+		)
+		sql := `SELECT 1 FROM pg_tables WHERE tablename = ?;`
+		if _, err = conn.Query(pg.Scan(&num), sql, fingerprintTable); err == nil && num == 1 {
+			sql = fmt.Sprintf("SELECT fingerprint FROM %v WHERE model_set = ?;", fingerprintTable)
+			if _, err = conn.Query(pg.Scan(&stored), sql, s.getModelSet()); err == nil {
+				flag = stored == fingerprint
+			}
+		}
+		if err != nil {
+			err = getWrapError(fingerprintTable, "fingerprint", sql, err)
+		}
+	}
+	return
+}
+
+//saveFingerprint will store fingerprint of the model set
+func (s *Shifter) saveFingerprint(conn *pg.DB, fingerprint string) (err error) {
+	if s.skipUnchanged {
+		err = s.retryTx(conn, func(tx *pg.Tx) (err error) {
+			sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+				model_set VARCHAR(64) PRIMARY KEY,
+				fingerprint VARCHAR(64) NOT NULL,
+				updated_at TIMESTAMPTZ NOT NULL DEFAULT now()
+			);`, fingerprintTable)
+			if _, err = tx.Exec(sql); err == nil {
+				sql = fmt.Sprintf("INSERT INTO %v (model_set, fingerprint) VALUES (?, ?) "+
+					"ON CONFLICT (model_set) DO UPDATE SET fingerprint = EXCLUDED.fingerprint, "+
+					"updated_at = now();", fingerprintTable)
+				_, err = tx.Exec(sql, s.getModelSet(), fingerprint)
+			}
+			if err != nil {
+				err = getWrapError(fingerprintTable, "save fingerprint", sql, err)
+			}
+			return
+		})
+	}
+	return
+}
+
+//getModelSet will return hash of table names which identifies the model set in fingerprint table
+func (s *Shifter) getModelSet() string {
+	h := sha256.New()
+	for _, tableName := range s.getTableNames() {
+		fmt.Fprintf(h, "%v\n", tableName)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+//getFingerprint will return deterministic hash of all the table models.
+//Maps are printed by fmt in sorted key order
+func (s *Shifter) getFingerprint() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "version %v\nenum %v\nignore %v\n", fingerprintVersion, s.enumList, s.ignore)
+	fmt.Fprintf(h, "online %v\nsoft drop %v\nguard %v\nidentity %v\n", s.online, s.softDrop, s.guard,
+		s.serialToIdentity)
+	for _, tableName := range s.getTableNames() {
+		fmt.Fprintf(h, "table %v\n", tableName)
+		if field, exists := getStructTableNameField(s.table[tableName]); exists {
+			fmt.Fprintf(h, "tag %v\n", field.Tag)
+		}
+		sSchema := s.GetStructSchema(tableName)
+		for _, col := range getSortedColumn(nil, sSchema) {
+			fmt.Fprintf(h, "column %+v\n", sSchema[col])
+		}
+		var backfill []string
+		for col := range s.getBackfillFromMethod(tableName) {
+			backfill = append(backfill, col)
+		}
+		sort.Strings(backfill)
+		fmt.Fprintf(h, "index %v\nunique %v\nenum %v\nignore %v\ntrigger %v\nbackfill %v\npost %v\n",
+			s.getIndexFromMethod(tableName), s.getUKFromMethod(tableName), s.getEnumFromMethod(tableName),
+			s.getIgnoreFromMethod(tableName), s.getTableTriggersTag(tableName), backfill,
+			s.getPostCreateSQLFromMethod(tableName))
+		for _, m := range s.getMigrationFromMethod(tableName) {
+			fmt.Fprintf(h, "migration %v\n", getMigrationChecksum(m))
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,196 @@
+package shifter
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//formatAlias is type name or alias to the name given by postgresql format_type.
+//serial types are integer types with a sequence
+var formatAlias = map[string]string{
+	"int2":        "smallint",
+	"smallserial": "smallint",
+	"serial2":     "smallint",
+	"int":         "integer",
+	"int4":        "integer",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"int8":        "bigint",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"float4":      "real",
+	"float8":      "double precision",
+	"decimal":     "numeric",
+	"bool":        "boolean",
+	"varchar":     "character varying",
+	"char":        "character",
+	"varbit":      "bit varying",
+	"timestamptz": "timestamp with time zone",
+	"timetz":      "time with time zone",
+}
+
+//tagTypeEnd is keywords of sql tag which ends the column type
+var tagTypeEnd = []string{" not null", " null", " default", " primary key", " unique", " references",
+	" check", " collate", " generated", " constraint", " deferrable", " initially"}
+
+var (
+	//arrayRegex matches array suffix of type like [], [3][3] or array
+	arrayRegex = regexp.MustCompile(`(\s*\[\d*\])+$|\s+array(\[\d*\])?$`)
+	//typeRegex matches type name, modifiers and time zone of time types
+	typeRegex = regexp.MustCompile(`^(.*?)\s*(?:\(\s*([^)]*?)\s*\))?\s*((?:with|without) time zone)?$`)
+	//typeModRegex matches modifiers of type like (10) or (10,2)
+	typeModRegex = regexp.MustCompile(`\(\s*[^)]*\)`)
+)
+
+//getTagType will return column type of sql tag including modifiers like varchar(10),
+//numeric(10,2), timestamp(3) with time zone or int[]
+func getTagType(tag string) (cType string) {
+	val := strings.SplitN(tag, "type:", 2)
+	if len(val) > 1 {
+		cType = " " + strings.Join(strings.Fields(cutTagOption(val[1])), " ")
+		for _, keyword := range tagTypeEnd {
+			if end := strings.Index(cType+" ", keyword+" "); end >= 0 {
+				cType = cType[:end]
+			}
+		}
+		cType = strings.TrimSpace(cType)
+	}
+	return
+}
+
+//cutTagOption will return tag value till the next tag option like ,array or ,notnull.
+//Comma inside parentheses or quotes is part of the value
+func cutTagOption(val string) string {
+	var (
+		depth int
+		quote bool
+	)
+	for i, c := range val {
+		switch {
+		case c == '\'':
+			quote = !quote
+		case quote:
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			return val[:i]
+		}
+	}
+	return val
+}
+
+//isArrayType will check full type is an array
+func isArrayType(fType string) bool {
+	return strings.HasSuffix(fType, "[]")
+}
+
+//formatType will return type in the form given by postgresql format_type
+//so struct tag type can be compared with database column type.
+//Aliases are resolved, precision/scale and time precision are kept and
+//array dimensions are reduced to [] as postgresql doesn't enforce them
+func formatType(cType string) (fType string) {
+	cType = strings.ToLower(strings.Join(strings.Fields(cType), " "))
+	isArray := false
+	if loc := arrayRegex.FindStringIndex(cType); loc != nil {
+		cType, isArray = cType[:loc[0]], true
+	} else if strings.HasPrefix(cType, "_") {
+		//array type by its udt name like _int4
+		cType, isArray = cType[1:], true
+	}
+	if m := typeRegex.FindStringSubmatch(cType); m != nil && cType != "" {
+		name, mod, zone := m[1], strings.Replace(m[2], " ", "", -1), m[3]
+		if alias, exists := formatAlias[name]; exists {
+			name = alias
+		}
+		switch name {
+		case "float":
+			//float(1) to float(24) is real
+			name = "double precision"
+			if p, err := strconv.Atoi(mod); err == nil && p >= 1 && p <= 24 {
+				name = "real"
+			}
+			mod = ""
+		case "numeric":
+			if mod != "" && strings.Contains(mod, ",") == false {
+				mod += ",0"
+			}
+		case "character", "bit":
+			if mod == "" {
+				mod = "1"
+			}
+		case "timestamp with time zone", "time with time zone":
+			name, zone = strings.TrimSuffix(name, " with time zone"), "with time zone"
+		case "timestamp", "time":
+			if zone == "" {
+				zone = "without time zone"
+			}
+		}
+		fType = name
+		if mod != "" {
+			fType += "(" + mod + ")"
+		}
+		if zone != "" {
+			fType += " " + zone
+		}
+	}
+	if isArray && fType != "" {
+		fType += "[]"
+	}
+	return
+}
+
+//setTypePrecision will set precision and scale of numeric and precision of
+//time, timestamp and interval from full type of struct tag.
+//Data type is set without precision like timestamp with time zone
+func setTypePrecision(schema *model.ColSchema) {
+	if m := typeRegex.FindStringSubmatch(schema.FullType); m != nil && isArrayType(schema.FullType) == false {
+		name, mod, zone := m[1], m[2], m[3]
+		switch {
+		case name == "numeric":
+			schema.DataType, schema.CharMaxLen = name, ""
+			if val := strings.Split(mod, ","); len(val) == 2 {
+				schema.NumericPrecision, schema.NumericScale = val[0], val[1]
+			}
+		case name == "time" || name == "timestamp" || strings.HasPrefix(name, "interval"):
+			if schema.DataType, schema.CharMaxLen = name, ""; zone != "" {
+				schema.DataType += " " + zone
+			} else if name != "time" && name != "timestamp" {
+				schema.DataType = "interval"
+			}
+			schema.DatetimePrecision = mod
+		}
+	}
+}
+
+//getTypePrecision will return precision modifier of numeric, time, timestamp and interval
+//like (10,2) or (3). Empty is returned if precision is not declared
+func getTypePrecision(schema model.ColSchema) (mod string) {
+	if schema.NumericPrecision != "" {
+		mod = "(" + schema.NumericPrecision + "," + schema.NumericScale + ")"
+	} else if schema.DatetimePrecision != "" {
+		mod = "(" + schema.DatetimePrecision + ")"
+	}
+	return
+}
+
+//isSameType will check table and struct column types are same.
+//Types are compared in format_type form if known at both sides
+func isSameType(tSchema, sSchema model.ColSchema) bool {
+	if tSchema.FullType != "" && sSchema.FullType != "" {
+		return tSchema.FullType == sSchema.FullType
+	}
+	return getStructDataType(tSchema) == getStructDataType(sSchema)
+}
+
+//getColumnType will return column type to alter to
+func getColumnType(schema model.ColSchema) (dType string) {
+	if dType = schema.FullType; dType == "" {
+		dType = getStructDataType(schema)
+	}
+	return
+}
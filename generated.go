@@ -0,0 +1,106 @@
+package shifter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//generatedRegex matches start of stored generated column clause of sql tag
+var generatedRegex = regexp.MustCompile(`generated\s+always\s+as\s*\(`)
+
+//getColGenerated will return generation expression of stored generated column from struct tag
+func getColGenerated(tag string) (expr string) {
+	if loc := generatedRegex.FindStringIndex(tag); loc != nil {
+		depth, quote := 1, false
+		for i := loc[1]; i < len(tag) && expr == ""; i++ {
+			switch c := tag[i]; {
+			case c == '\'':
+				quote = !quote
+			case quote:
+			case c == '(':
+				depth++
+			case c == ')':
+				if depth--; depth == 0 {
+					expr = strings.TrimSpace(tag[loc[1]:i])
+				}
+			}
+		}
+	}
+	return
+}
+
+//getGeneratedSQL will return stored generated column clause
+func getGeneratedSQL(schema model.ColSchema) (sql string) {
+	if schema.Generated != "" {
+		sql = fmt.Sprintf(" GENERATED ALWAYS AS (%v) STORED", schema.Generated)
+	}
+	return
+}
+
+//isSameGenerated will check table and struct generated columns are same.
+//Type of generated column is also compared as column is recreated to change it
+func isSameGenerated(tSchema, sSchema model.ColSchema) (isSame bool) {
+	if isSame = isSameGeneratedExpr(tSchema, sSchema); isSame && sSchema.Generated != "" {
+		isSame = isSameType(tSchema, sSchema)
+	}
+	return
+}
+
+//isSameGeneratedExpr will check table and struct generation expressions are same
+//by text normalized same as function defaults, so it doesn't run any ddl
+func isSameGeneratedExpr(tSchema, sSchema model.ColSchema) bool {
+	return normalizeExpr(tSchema.Generated) == normalizeExpr(sSchema.Generated)
+}
+
+//modifyGenerated will modify generated column by comparing table and structure.
+//Column is recreated with its indexes if expression or type is changed or plain column is made generated
+//and expression is dropped if column is no more generated keeping its values
+func (s *Shifter) modifyGenerated(tx *pg.Tx, tSchema, sSchema model.ColSchema,
+	skipPrompt bool) (isAlter bool, err error) {
+
+	if isSameGenerated(tSchema, sSchema) == false {
+		tName, cName := sSchema.TableName, sSchema.ColumnName
+		sql := ""
+		if sSchema.Generated == "" {
+			sql = fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v DROP EXPRESSION;\n", tName, cName)
+		} else {
+			var idxDef []string
+			if idxDef, err = getColumnIndexDef(tx, tName, cName); err == nil {
+				sql = getDropColSQL(tName, cName)
+				sql += getAddColSQL(tName, cName, getAddColTypeSQL(sSchema)) + ";\n"
+				for _, def := range idxDef {
+					sql += def + ";\n"
+				}
+			} else {
+				err = getWrapError(tName, "column index", cName, err)
+			}
+		}
+		if err == nil {
+			step := Step{Table: tName, Column: cName, Op: opModifyGenerated,
+				Rewrite: sSchema.Generated != "", Scan: sSchema.Generated != "", SQL: sql}
+			if sSchema.Generated == "" {
+				step.Class = SafeChange
+			}
+			if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+				err = getWrapError(tName, opModifyGenerated, sql, err)
+			}
+		}
+	}
+	return
+}
+
+//getColumnIndexDef will return definition of indexes on column which are dropped along with the column.
+//Indexes of constraints are not included as constraints are compared separately
+func getColumnIndexDef(tx *pg.Tx, tableName, column string) (idxDef []string, err error) {
+	sql := `SELECT DISTINCT pg_get_indexdef(d.objid) FROM pg_depend d
+	JOIN pg_index i ON i.indexrelid = d.objid
+	JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+	WHERE d.classid = 'pg_class'::regclass AND d.refobjid = ?::regclass AND a.attname = ?
+	AND NOT EXISTS (SELECT 1 FROM pg_constraint c WHERE c.conindid = d.objid);`
+	_, err = tx.Query(&idxDef, sql, tableName, column)
+	return
+}
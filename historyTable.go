@@ -23,15 +23,6 @@ func (s *Shifter) createHistory(tx *pg.Tx, tableName string) (err error) {
 	return
 }
 
-//dropHistory will drop history table
-func (s *Shifter) dropHistory(tx *pg.Tx, tableName string, cascade bool) (err error) {
-	historyTable := util.GetHistoryTableName(tableName)
-	if tableExists := tableExists(tx, historyTable); tableExists == true {
-		err = execTableDrop(tx, historyTable, cascade)
-	}
-	return
-}
-
 //dropHistoryConstraint will drop history table constraints
 func (s *Shifter) dropHistoryConstraint(tx *pg.Tx, historyTable string) (err error) {
 	sql := `
@@ -0,0 +1,106 @@
+package shifter
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//BeforeCreateHook is implemented by table model to run sql before table is created
+type BeforeCreateHook interface {
+	BeforeCreate(tx *pg.Tx) error
+}
+
+//AfterCreateHook is implemented by table model to run sql after table, history and PostCreateSQL are created
+type AfterCreateHook interface {
+	AfterCreate(tx *pg.Tx) error
+}
+
+//BeforeAlterHook is implemented by table model to run sql before table is altered.
+//plan contains the steps which will be executed
+type BeforeAlterHook interface {
+	BeforeAlter(tx *pg.Tx, plan []Step) error
+}
+
+//AfterAlterHook is implemented by table model to run sql after table is altered
+type AfterAlterHook interface {
+	AfterAlter(tx *pg.Tx, result TableResult) error
+}
+
+//AfterColumnAddedHook is implemented by table model to run sql after a column is added.
+//Backfill and not null of the column are done after commit so column can be null here
+type AfterColumnAddedHook interface {
+	AfterColumnAdded(tx *pg.Tx, column string) error
+}
+
+//TableResult is result of table alter passed to AfterAlter hook
+type TableResult struct {
+	Table    string `json:"table"`
+	Steps    []Step `json:"steps"`    //steps executed in the transaction
+	Deferred []Step `json:"deferred"` //steps which will be executed after commit
+}
+
+//beforeCreate will call BeforeCreate hook of table model
+func (s *Shifter) beforeCreate(tx *pg.Tx, tableName string) (err error) {
+	if hook, isValid := s.table[tableName].(BeforeCreateHook); isValid {
+		if err = hook.BeforeCreate(tx); err != nil {
+			err = getWrapError(tableName, "BeforeCreate hook", "", err)
+		}
+	}
+	return
+}
+
+//afterCreate will call AfterCreate hook of table model
+func (s *Shifter) afterCreate(tx *pg.Tx, tableName string) (err error) {
+	if hook, isValid := s.table[tableName].(AfterCreateHook); isValid {
+		if err = hook.AfterCreate(tx); err != nil {
+			err = getWrapError(tableName, "AfterCreate hook", "", err)
+		}
+	}
+	return
+}
+
+//beforeAlter will call BeforeAlter hook of table model with the plan of alter.
+//Plan is created by running the alter in plan mode in same transaction
+func (s *Shifter) beforeAlter(tx *pg.Tx, tableName string, tSchema,
+	sSchema map[string]model.ColSchema, skipPrompt bool) (err error) {
+
+	hook, isValid := s.table[tableName].(BeforeAlterHook)
+	if isValid && s.plan == false {
+		steps, blocked, failed := s.steps, s.blocked, s.failed
+		s.plan, s.steps = true, nil
+		_, _, err = s.alterSchema(tx, tableName, tSchema, s.removeIgnoredColumn(tableName, sSchema), skipPrompt)
+		plan := s.steps
+		s.plan, s.steps, s.blocked, s.failed = false, steps, blocked, failed
+		if err == nil {
+			//hook can change any table
+			defer s.catalog.reset()
+			if err = hook.BeforeAlter(tx, plan); err != nil {
+				err = getWrapError(tableName, "BeforeAlter hook", "", err)
+			}
+		}
+	}
+	return
+}
+
+//afterAlter will call AfterAlter hook of table model with the steps executed after given index
+func (s *Shifter) afterAlter(tx *pg.Tx, tableName string, stepIdx, deferIdx int) (err error) {
+	if hook, isValid := s.table[tableName].(AfterAlterHook); isValid && s.plan == false {
+		result := TableResult{Table: tableName, Steps: s.steps[stepIdx:], Deferred: s.deferred[deferIdx:]}
+		defer s.catalog.reset()
+		if err = hook.AfterAlter(tx, result); err != nil {
+			err = getWrapError(tableName, "AfterAlter hook", "", err)
+		}
+	}
+	return
+}
+
+//afterColumnAdded will call AfterColumnAdded hook of table model
+func (s *Shifter) afterColumnAdded(tx *pg.Tx, schema model.ColSchema) (err error) {
+	if hook, isValid := s.table[schema.TableName].(AfterColumnAddedHook); isValid && s.plan == false {
+		defer s.catalog.reset()
+		if err = hook.AfterColumnAdded(tx, schema.ColumnName); err != nil {
+			err = getWrapError(schema.TableName, "AfterColumnAdded hook", "", err)
+		}
+	}
+	return
+}
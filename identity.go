@@ -0,0 +1,128 @@
+package shifter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//identity generation of column as in information_schema
+const (
+	identityAlways    = "ALWAYS"
+	identityByDefault = "BY DEFAULT"
+)
+
+//identityRegex matches identity clause of sql tag
+var identityRegex = regexp.MustCompile(`generated\s+(always|by\s+default)\s+as\s+identity`)
+
+// SerialToIdentity will convert serial columns to identity columns
+// when struct tag has GENERATED {ALWAYS|BY DEFAULT} AS IDENTITY.
+//
+// Default of the column is dropped along with its sequence and identity is started
+// from next value of the dropped sequence. Disabled by default.
+func (s *Shifter) SerialToIdentity(enable bool) *Shifter {
+	s.serialToIdentity = enable
+	return s
+}
+
+//getColIdentity will return identity generation of column from struct tag
+func getColIdentity(tag string) (identity string) {
+	if m := identityRegex.FindStringSubmatch(tag); m != nil {
+		identity = identityAlways
+		if m[1] != "always" {
+			identity = identityByDefault
+		}
+	}
+	return
+}
+
+//isSerialType will check data type is serial, bigserial or smallserial
+func isSerialType(dType string) bool {
+	return strings.Contains(dType, "serial")
+}
+
+//getIdentitySQL will return identity clause of column
+func getIdentitySQL(schema model.ColSchema) (sql string) {
+	if schema.Identity != "" {
+		sql = fmt.Sprintf(" GENERATED %v AS IDENTITY", schema.Identity)
+	}
+	return
+}
+
+//getSeqTypeSQL will return sql to change sequence type of serial or identity column
+//when column is changed to other integer type
+func getSeqTypeSQL(tSchema, sSchema model.ColSchema) (sql string) {
+	sType := getBaseType(sSchema)
+	if tSchema.SeqName != "" && sType != tSchema.SeqDataType &&
+		(sType == "smallint" || sType == "integer" || sType == "bigint") {
+		sql = fmt.Sprintf("ALTER SEQUENCE %v AS %v;\n", tSchema.SeqName, sType)
+	}
+	return
+}
+
+//modifyIdentity will modify identity or serial of column by comparing table and structure.
+//Identity is added, dropped or its generation is changed and sequence is created for
+//column changed to serial. Serial is converted to identity only if enabled by SerialToIdentity()
+func (s *Shifter) modifyIdentity(tx *pg.Tx, tSchema, sSchema model.ColSchema,
+	skipPrompt bool) (isAlter bool, err error) {
+
+	sql := ""
+	tName, cName := sSchema.TableName, sSchema.ColumnName
+	switch {
+	case tSchema.Identity == sSchema.Identity:
+		if tSchema.SeqName == "" && isSerialType(sSchema.DataType) {
+			sql = getAddSerialSQL(sSchema)
+		}
+	case sSchema.Identity == "":
+		sql = fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v DROP IDENTITY IF EXISTS;\n", tName, cName)
+		if isSerialType(sSchema.DataType) {
+			sql += getAddSerialSQL(sSchema)
+		}
+	case tSchema.Identity != "":
+		sql = fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v SET GENERATED %v;\n", tName, cName, sSchema.Identity)
+	case tSchema.SeqName == "":
+		sql = getNotNullColSQL(tName, cName, set) + ";\n"
+		sql += fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v ADD%v;\n", tName, cName, getIdentitySQL(sSchema))
+		sql += fmt.Sprintf("SELECT setval(pg_get_serial_sequence('%v', '%v'), COALESCE(max(%v), 0) + 1, false) FROM %v;\n",
+			tName, cName, cName, tName)
+	case s.serialToIdentity:
+		var start int64
+		if start, err = getSeqNextValue(tx, tSchema.SeqName); err == nil {
+			sql = getDropDefaultSQL(tName, cName)
+			sql += fmt.Sprintf("DROP SEQUENCE %v;\n", tSchema.SeqName)
+			sql += fmt.Sprintf("ALTER TABLE %v ALTER COLUMN %v ADD%v (START WITH %v);\n",
+				tName, cName, getIdentitySQL(sSchema), start)
+		} else {
+			err = getWrapError(tName, "sequence value", tSchema.SeqName, err)
+		}
+	}
+	if sql != "" && err == nil {
+		step := Step{Table: tName, Column: cName, Op: opModifyIdentity, Scan: tSchema.SeqName == "", SQL: sql}
+		if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+			err = getWrapError(tName, opModifyIdentity, sql, err)
+		}
+	}
+	return
+}
+
+//getAddSerialSQL will return sql to create sequence owned by the column
+//starting after max value of column and set it as column default
+func getAddSerialSQL(schema model.ColSchema) (sql string) {
+	tName, cName := schema.TableName, schema.ColumnName
+	seqName := fmt.Sprintf("%v_%v_seq", tName, cName)
+	sql = fmt.Sprintf("CREATE SEQUENCE IF NOT EXISTS %v AS %v OWNED BY %v.%v;\n",
+		seqName, getBaseType(schema), tName, cName)
+	sql += fmt.Sprintf("SELECT setval('%v', COALESCE(max(%v), 0) + 1, false) FROM %v;\n", seqName, cName, tName)
+	sql += getSetDefaultSQL(tName, cName, fmt.Sprintf("nextval('%v'::regclass)", seqName))
+	return
+}
+
+//getSeqNextValue will return value which will be returned by next call of nextval on sequence
+func getSeqNextValue(tx *pg.Tx, seqName string) (value int64, err error) {
+	sql := fmt.Sprintf("SELECT CASE WHEN is_called THEN last_value + 1 ELSE last_value END FROM %v", seqName)
+	_, err = tx.QueryOne(pg.Scan(&value), sql)
+	return
+}
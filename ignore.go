@@ -0,0 +1,122 @@
+package shifter
+
+import (
+	"path"
+	"reflect"
+	"strings"
+
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//ignore rule kind
+const (
+	IgnoreTable  = "table"  //ignore whole table
+	IgnoreColumn = "column" //ignore table column
+	IgnoreIndex  = "index"  //ignore table index
+)
+
+// Ignore will exclude externally managed objects from comparison.
+//
+// kind can be IgnoreTable, IgnoreColumn or IgnoreIndex.
+// patterns are matched using path.Match so glob like analytics_* can be used.
+// For column and index pattern can be prefixed by table name (table.pattern)
+// to ignore it only in that table else it will be ignored in all the tables.
+func (s *Shifter) Ignore(kind string, patterns ...string) *Shifter {
+	if s.ignore == nil {
+		s.ignore = make(map[string][]string)
+	}
+	s.ignore[kind] = append(s.ignore[kind], patterns...)
+	return s
+}
+
+//isIgnoredTable will check table is ignored from comparison
+//by shifter patterns and table Ignore() method
+func (s *Shifter) isIgnoredTable(tableName string) bool {
+	return matchAny(s.ignore[IgnoreTable], tableName) ||
+		matchAny(s.getIgnoreFromMethod(tableName)[IgnoreTable], tableName)
+}
+
+//isIgnoredColumn will check table column is ignored from comparison
+func (s *Shifter) isIgnoredColumn(tableName, column string) bool {
+	return s.isIgnored(tableName, IgnoreColumn, column)
+}
+
+//isIgnoredIndex will check table index is ignored from comparison
+func (s *Shifter) isIgnoredIndex(tableName, idxName string) bool {
+	return s.isIgnored(tableName, IgnoreIndex, idxName)
+}
+
+//isIgnored will check object of given kind is ignored in table
+//by shifter global/table prefixed patterns and table Ignore() method
+func (s *Shifter) isIgnored(tableName, kind, name string) (flag bool) {
+	for _, pattern := range s.ignore[kind] {
+		if tPattern, oPattern := splitIgnorePattern(pattern); matchAny([]string{tPattern}, tableName) &&
+			matchAny([]string{oPattern}, name) {
+			flag = true
+			break
+		}
+	}
+	if flag == false {
+		flag = matchAny(s.getIgnoreFromMethod(tableName)[kind], name)
+	}
+	return
+}
+
+//removeIgnoredColumn will return schema without ignored columns
+func (s *Shifter) removeIgnoredColumn(tableName string,
+	schema map[string]model.ColSchema) (cSchema map[string]model.ColSchema) {
+
+	cSchema = make(map[string]model.ColSchema)
+	for col, colSchema := range schema {
+		if s.isIgnoredColumn(tableName, col) == false {
+			cSchema[col] = colSchema
+		}
+	}
+	return
+}
+
+//removeIgnoredIndex will return indexes which are not ignored
+func (s *Shifter) removeIgnoredIndex(tableName string, idx []model.Index) (cIdx []model.Index) {
+	for _, curIdx := range idx {
+		if s.isIgnoredIndex(tableName, curIdx.IdxName) == false {
+			cIdx = append(cIdx, curIdx)
+		}
+	}
+	return
+}
+
+//getIgnoreFromMethod will return table ignore rules from Ignore() method associated to table structure
+func (s *Shifter) getIgnoreFromMethod(tableName string) (ignore map[string][]string) {
+	if dbModel, exists := s.table[tableName]; exists {
+		refObj := reflect.ValueOf(dbModel)
+		m := refObj.MethodByName("Ignore")
+		if m.IsValid() && m.Type().NumIn() == 0 {
+			out := m.Call([]reflect.Value{})
+			if len(out) > 0 && out[0].Kind() == reflect.Map {
+				ignore, _ = out[0].Interface().(map[string][]string)
+			}
+		}
+	}
+	return
+}
+
+//splitIgnorePattern will split pattern in table and object pattern
+//if table is not given in pattern then all tables are matched
+func splitIgnorePattern(pattern string) (tPattern, oPattern string) {
+	tPattern, oPattern = "*", pattern
+	if idx := strings.Index(pattern, "."); idx > 0 {
+		tPattern, oPattern = pattern[:idx], pattern[idx+1:]
+	}
+	return
+}
+
+//matchAny will check name is matching with any pattern
+func matchAny(patterns []string, name string) (flag bool) {
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, name); err == nil && matched {
+			flag = true
+			break
+		}
+	}
+	return
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
 	"github.com/mayur-tolexo/pg-shifter/model"
 	"github.com/mayur-tolexo/pg-shifter/util"
 )
@@ -23,11 +24,16 @@ const (
 //Create index of given table
 func (s *Shifter) createIndex(tx *pg.Tx, tableName string, skipPrompt bool) (err error) {
 	var indexSQL string
+	if s.isIgnoredTable(tableName) {
+		return
+	}
 	for index, idxType := range s.getIndexFromMethod(tableName) {
-		indexSQL += getIndexQuery(tableName, idxType, index)
+		if s.isIgnoredIndex(tableName, getIndexName(tableName, index)) == false {
+			indexSQL += getIndexQuery(tableName, idxType, index)
+		}
 	}
 	if indexSQL != "" {
-		choice := util.GetChoice("INDEX:\n"+indexSQL, skipPrompt)
+		choice := s.getChoice("INDEX:\n"+indexSQL, skipPrompt)
 		if choice == util.Yes {
 			if _, err = tx.Exec(indexSQL); err != nil {
 				err = getWrapError(tableName, "create index", indexSQL, err)
@@ -40,12 +46,18 @@ func (s *Shifter) createIndex(tx *pg.Tx, tableName string, skipPrompt bool) (err
 //Get index query by tablename and table columns
 func getIndexQuery(tableName string, indexDS string, column string) (uniqueKeyQuery string) {
 	indexDS = getIndexType(indexDS)
-	constraintName := fmt.Sprintf("idx_%v_%v", tableName, strings.Replace(strings.Replace(column, " ", "", -1), ",", "_", -1))
-	constraintName = util.GetStrByLen(constraintName, 64)
+	constraintName := getIndexName(tableName, column)
 	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %v ON %v USING %v (%v);\n",
 		constraintName, tableName, indexDS, column)
 }
 
+//getIndexName will return index name by tablename and table columns
+func getIndexName(tableName string, column string) (idxName string) {
+	idxName = fmt.Sprintf("idx_%v_%v", tableName, strings.Replace(strings.Replace(column, " ", "", -1), ",", "_", -1))
+	idxName = util.GetStrByLen(idxName, 64)
+	return
+}
+
 //getIndexType will return index type to use
 func getIndexType(iType string) (idxType string) {
 	switch iType {
@@ -81,33 +93,9 @@ func (s *Shifter) getIndexFromMethod(tableName string) (idx map[string]string) {
 
 //getDBIndex : Get index of table from database
 func getDBIndex(tx *pg.Tx, tableName string) (idx []model.Index, err error) {
-	query := `
-	with idx as (
-		select
-		--    t.relname as table_name
-		    i.relname as index_name
-		    , c.column_name
-		    , am.amname
-		    , array_position(ix.indkey::int[],c.ordinal_position::int) as position
-		from
-			pg_index ix
-			join pg_class t on  t.oid = ix.indrelid
-		    join pg_class i on i.oid = ix.indexrelid
-		    JOIN pg_am am ON am.oid = i.relam
-		    join unnest(ix.indkey::int[]) as colNo on true 
-		    join information_schema.columns as c 
-			on c.ordinal_position = colNo and c.table_name = t.relname
-		where
-		    t.relkind = 'r'
-		    and ix.indisunique = false
-		    and t.relname = ?
-		   order by i.relname, position
-	)
-	select index_name 
-	, string_agg(distinct amname,',') as itype
-	, string_agg(column_name,',') as col
-	from idx
-	group by index_name;`
-	_, err = tx.Query(&idx, query, tableName)
+	var index []inspect.Index
+	if index, err = inspect.Indexes(tx, "", tableName); err == nil {
+		idx = toIndex(index)
+	}
 	return
 }
@@ -0,0 +1,71 @@
+package inspect
+
+import "github.com/go-pg/pg/orm"
+
+//Column is description of a table column
+type Column struct {
+	Table         string `sql:"table_name" json:"table"`
+	Name          string `sql:"column_name" json:"name"`
+	Position      int    `sql:"position" json:"position"`
+	DataType      string `sql:"data_type" json:"data_type"`           //as in information_schema e.g. character varying, ARRAY, USER-DEFINED
+	UdtName       string `sql:"udt_name" json:"udt_name"`             //underlying type e.g. varchar, _int4 or enum name
+	FullType      string `sql:"full_type" json:"full_type"`           //type with modifiers by format_type e.g. numeric(10,2), integer[]
+	CharMaxLen    int    `sql:"char_max_len" json:"char_max_len"`     //zero if not a character type or no limit
+	Precision     int    `sql:"precision" json:"precision"`           //declared precision of numeric, zero if not declared
+	Scale         int    `sql:"scale" json:"scale"`                   //declared scale of numeric
+	TimePrecision *int   `sql:"time_precision" json:"time_precision"` //declared precision of time, timestamp or interval, nil if not declared
+	Nullable      bool   `sql:"nullable" json:"nullable"`
+	Default       string `sql:"column_default" json:"default"`
+	Collation     string `sql:"collation" json:"collation"` //empty if default collation of type
+	Identity      string `sql:"identity" json:"identity"`   //ALWAYS or BY DEFAULT for identity column
+	Generated     string `sql:"generated" json:"generated"` //expression of stored generated column
+	SeqName       string `sql:"seq_name" json:"seq_name"`   //sequence owned by serial or identity column
+	SeqDataType   string `sql:"seq_data_type" json:"seq_data_type"`
+}
+
+//Columns will return columns of given tables of schema in table and position order.
+//Columns of all the tables of schema are returned if no table is given.
+//Columns are read from pg_attribute and described as in information_schema.columns.
+//Identity and generated flags are read from pg_attribute as json so
+//the query works on versions which don't have them
+func Columns(db orm.DB, schema string, tables ...string) (column []Column, err error) {
+	where, params := getFilter("c.relname", tableVisible, schema, tables)
+	query := `SELECT c.relname AS table_name, a.attname AS column_name, a.attnum AS position,
+	CASE WHEN ty.elem <> 0 AND ty.len = -1 THEN 'ARRAY'
+	WHEN ty.nsp = 'pg_catalog'::regnamespace THEN format_type(ty.oid, NULL)
+	ELSE 'USER-DEFINED' END AS data_type,
+	ty.name AS udt_name, format_type(a.atttypid, a.atttypmod) AS full_type,
+	CASE WHEN ty.typmod < 0 THEN NULL
+	WHEN ty.oid IN ('bpchar'::regtype, 'varchar'::regtype) THEN ty.typmod - 4
+	WHEN ty.oid IN ('bit'::regtype, 'varbit'::regtype) THEN ty.typmod END AS char_max_len,
+	CASE WHEN ty.oid = 'numeric'::regtype AND ty.typmod >= 0 THEN ((ty.typmod - 4) >> 16) & 65535 END AS precision,
+	CASE WHEN ty.oid = 'numeric'::regtype AND ty.typmod >= 0 THEN (ty.typmod - 4) & 65535 END AS scale,
+	CASE WHEN ty.typmod < 0 OR ty.category NOT IN ('D', 'T') THEN NULL
+	WHEN ty.oid <> 'interval'::regtype THEN ty.typmod
+	WHEN ty.typmod & 65535 = 65535 THEN 6 ELSE ty.typmod & 65535 END AS time_precision,
+	NOT (a.attnotnull OR (t.typtype = 'd' AND t.typnotnull)) AS nullable,
+	CASE WHEN COALESCE(j.attr ->> 'attgenerated', '') = '' THEN pg_get_expr(ad.adbin, ad.adrelid) END AS column_default,
+	CASE WHEN a.attcollation <> t.typcollation THEN co.collname END AS collation,
+	CASE j.attr ->> 'attidentity' WHEN 'a' THEN 'ALWAYS' WHEN 'd' THEN 'BY DEFAULT' END AS identity,
+	CASE WHEN j.attr ->> 'attgenerated' = 's' THEN pg_get_expr(ad.adbin, ad.adrelid) END AS generated,
+	sq.relname AS seq_name, format_type(s.seqtypid, NULL) AS seq_data_type
+	FROM pg_attribute a
+	JOIN pg_class c ON c.oid = a.attrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_type t ON t.oid = a.atttypid
+	LEFT JOIN pg_type bt ON t.typtype = 'd' AND bt.oid = t.typbasetype
+	CROSS JOIN LATERAL (SELECT COALESCE(bt.oid, t.oid) AS oid, COALESCE(bt.typname, t.typname) AS name,
+		COALESCE(bt.typnamespace, t.typnamespace) AS nsp, COALESCE(bt.typelem, t.typelem) AS elem,
+		COALESCE(bt.typlen, t.typlen) AS len, COALESCE(bt.typcategory, t.typcategory) AS category,
+		CASE WHEN t.typtype = 'd' THEN t.typtypmod ELSE a.atttypmod END AS typmod) ty
+	CROSS JOIN LATERAL (SELECT to_jsonb(a) AS attr) j
+	LEFT JOIN pg_attrdef ad ON ad.adrelid = a.attrelid AND ad.adnum = a.attnum
+	LEFT JOIN pg_collation co ON co.oid = a.attcollation
+	LEFT JOIN pg_class sq ON sq.oid = pg_get_serial_sequence(
+		quote_ident(n.nspname) || '.' || quote_ident(c.relname), a.attname)::regclass
+	LEFT JOIN pg_sequence s ON s.seqrelid = sq.oid
+	WHERE a.attnum > 0 AND NOT a.attisdropped AND c.relkind IN ('r', 'p') AND ` + where + `
+	ORDER BY c.relname, a.attnum;`
+	_, err = db.Query(&column, query, params...)
+	return
+}
@@ -0,0 +1,78 @@
+package inspect
+
+import "github.com/go-pg/pg/orm"
+
+//Constraint is description of a table constraint
+type Constraint struct {
+	Table             string   `sql:"table_name" json:"table"`
+	Name              string   `sql:"name" json:"name"`
+	Type              string   `sql:"type" json:"type"` //PRIMARY KEY, FOREIGN KEY, UNIQUE, CHECK or EXCLUDE
+	Columns           []string `sql:"columns,array" json:"columns"`
+	ForeignTable      string   `sql:"foreign_table" json:"foreign_table"`
+	ForeignColumns    []string `sql:"foreign_columns,array" json:"foreign_columns"`
+	UpdateRule        string   `sql:"update_rule" json:"update_rule"` //confupdtype of pg_constraint a, r, c, n or d
+	DeleteRule        string   `sql:"delete_rule" json:"delete_rule"` //confdeltype of pg_constraint a, r, c, n or d
+	Deferrable        bool     `sql:"deferrable" json:"deferrable"`
+	InitiallyDeferred bool     `sql:"initially_deferred" json:"initially_deferred"`
+	Validated         bool     `sql:"validated" json:"validated"`
+	Definition        string   `sql:"definition" json:"definition"`
+}
+
+//Index is description of a table index
+type Index struct {
+	Table      string   `sql:"table_name" json:"table"`
+	Name       string   `sql:"name" json:"name"`
+	Method     string   `sql:"method" json:"method"`
+	Columns    []string `sql:"columns,array" json:"columns"` //expression keys are not included
+	Unique     bool     `sql:"is_unique" json:"unique"`
+	Primary    bool     `sql:"is_primary" json:"primary"`
+	Definition string   `sql:"definition" json:"definition"`
+}
+
+//Constraints will return constraints of given tables of schema.
+//Columns are in key order. Constraints of all the tables of schema are returned if no table is given
+func Constraints(db orm.DB, schema string, tables ...string) (constraint []Constraint, err error) {
+	where, params := getFilter("c.relname", tableVisible, schema, tables)
+	query := `SELECT c.relname AS table_name, con.conname AS name,
+	CASE con.contype WHEN 'p' THEN 'PRIMARY KEY' WHEN 'f' THEN 'FOREIGN KEY' WHEN 'u' THEN 'UNIQUE'
+	WHEN 'c' THEN 'CHECK' WHEN 'x' THEN 'EXCLUDE' ELSE con.contype::text END AS type,
+	ARRAY(SELECT a.attname FROM unnest(con.conkey) WITH ORDINALITY k(num, ord)
+		JOIN pg_attribute a ON a.attrelid = con.conrelid AND a.attnum = k.num
+		ORDER BY k.ord)::text[] AS columns,
+	fc.relname AS foreign_table,
+	ARRAY(SELECT a.attname FROM unnest(con.confkey) WITH ORDINALITY k(num, ord)
+		JOIN pg_attribute a ON a.attrelid = con.confrelid AND a.attnum = k.num
+		ORDER BY k.ord)::text[] AS foreign_columns,
+	con.confupdtype AS update_rule, con.confdeltype AS delete_rule,
+	con.condeferrable AS deferrable, con.condeferred AS initially_deferred,
+	con.convalidated AS validated, pg_get_constraintdef(con.oid) AS definition
+	FROM pg_constraint con
+	JOIN pg_class c ON c.oid = con.conrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_class fc ON fc.oid = con.confrelid
+	WHERE ` + where + `
+	ORDER BY c.relname, con.conname;`
+	_, err = db.Query(&constraint, query, params...)
+	return
+}
+
+//Indexes will return indexes of given tables of schema including unique and primary key indexes.
+//Indexes of all the tables of schema are returned if no table is given
+func Indexes(db orm.DB, schema string, tables ...string) (index []Index, err error) {
+	where, params := getFilter("c.relname", tableVisible, schema, tables)
+	query := `SELECT c.relname AS table_name, i.relname AS name, am.amname AS method,
+	ARRAY(SELECT a.attname FROM unnest(ix.indkey::int2[]) WITH ORDINALITY k(num, ord)
+		JOIN pg_attribute a ON a.attrelid = ix.indrelid AND a.attnum = k.num
+		ORDER BY k.ord)::text[] AS columns,
+	ix.indisunique AS is_unique, ix.indisprimary AS is_primary,
+	pg_get_indexdef(ix.indexrelid) AS definition
+	FROM pg_index ix
+	JOIN pg_class c ON c.oid = ix.indrelid
+	JOIN pg_class i ON i.oid = ix.indexrelid
+	JOIN pg_am am ON am.oid = i.relam
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'p') AND ` + where + `
+	ORDER BY c.relname, i.relname;`
+	_, err = db.Query(&index, query, params...)
+	return
+}
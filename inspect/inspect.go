@@ -0,0 +1,117 @@
+//Package inspect describes tables, columns, constraints, indexes, enums, sequences,
+//triggers and views of a postgresql schema from the system catalog.
+//
+//Empty schema means the schemas of search path, where an object hidden by a same named object of a schema
+//earlier in search path is skipped. Inspect describes current schema if empty. All the functions accept *pg.DB or *pg.Tx.
+//Sequences are read from pg_sequence which needs postgresql 10 or above.
+package inspect
+
+import (
+	"github.com/go-pg/pg"
+	"github.com/go-pg/pg/orm"
+)
+
+//visibility conditions of relation and type by search path
+const (
+	tableVisible = "pg_table_is_visible(c.oid)"
+	typeVisible  = "pg_type_is_visible(t.oid)"
+)
+
+//Schema is description of a database schema
+type Schema struct {
+	Name      string     `json:"name"`
+	Tables    []Table    `json:"tables"`
+	Enums     []Enum     `json:"enums"`
+	Sequences []Sequence `json:"sequences"`
+	Views     []View     `json:"views"`
+}
+
+//Table is description of a table
+type Table struct {
+	Name        string       `json:"name"`
+	Columns     []Column     `json:"columns"`
+	Constraints []Constraint `json:"constraints"`
+	Indexes     []Index      `json:"indexes"`
+	Triggers    []Trigger    `json:"triggers"`
+}
+
+//Inspect will return description of given tables of schema.
+//All the tables of schema are described if no table is given
+func Inspect(db orm.DB, schema string, tables ...string) (s Schema, err error) {
+	var (
+		column     []Column
+		constraint []Constraint
+		index      []Index
+		trigger    []Trigger
+	)
+	if _, err = db.QueryOne(pg.Scan(&s.Name), "SELECT COALESCE(NULLIF(?, ''), current_schema())", schema); err != nil {
+		return
+	}
+	if tables, err = Tables(db, s.Name, tables...); err == nil && len(tables) > 0 {
+		if column, err = Columns(db, s.Name, tables...); err == nil {
+			if constraint, err = Constraints(db, s.Name, tables...); err == nil {
+				if index, err = Indexes(db, s.Name, tables...); err == nil {
+					trigger, err = Triggers(db, s.Name, tables...)
+				}
+			}
+		}
+	}
+	if err == nil {
+		pos := make(map[string]int, len(tables))
+		for i, tableName := range tables {
+			pos[tableName] = i
+			s.Tables = append(s.Tables, Table{Name: tableName})
+		}
+		for _, col := range column {
+			t := &s.Tables[pos[col.Table]]
+			t.Columns = append(t.Columns, col)
+		}
+		for _, con := range constraint {
+			t := &s.Tables[pos[con.Table]]
+			t.Constraints = append(t.Constraints, con)
+		}
+		for _, idx := range index {
+			t := &s.Tables[pos[idx.Table]]
+			t.Indexes = append(t.Indexes, idx)
+		}
+		for _, trg := range trigger {
+			t := &s.Tables[pos[trg.Table]]
+			t.Triggers = append(t.Triggers, trg)
+		}
+		if s.Enums, err = Enums(db, s.Name); err == nil {
+			if s.Sequences, err = Sequences(db, s.Name); err == nil {
+				s.Views, err = Views(db, s.Name)
+			}
+		}
+	}
+	return
+}
+
+//Tables will return names of given tables which exist in schema.
+//All the tables of schema are returned if no table is given
+func Tables(db orm.DB, schema string, tables ...string) (name []string, err error) {
+	where, params := getFilter("c.relname", tableVisible, schema, tables)
+	query := `SELECT c.relname
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('r', 'p') AND ` + where + `
+	ORDER BY c.relname;`
+	_, err = db.Query(&name, query, params...)
+	return
+}
+
+//getFilter will return where condition of schema and given names with its params.
+//Without schema objects of search path schemas are matched which are visible by visible condition
+//so an object hidden by same named object of a schema earlier in search path is not matched
+func getFilter(nameCol, visible, schema string, names []string) (where string, params []interface{}) {
+	if schema == "" {
+		where = "n.nspname = ANY(current_schemas(false)) AND " + visible
+	} else {
+		where, params = "n.nspname = ?", []interface{}{schema}
+	}
+	if len(names) > 0 {
+		where += " AND " + nameCol + " = ANY(?)"
+		params = append(params, pg.Array(names))
+	}
+	return
+}
@@ -0,0 +1,112 @@
+package inspect
+
+import "github.com/go-pg/pg/orm"
+
+//Enum is description of an enum type
+type Enum struct {
+	Name   string   `sql:"name" json:"name"`
+	Values []string `sql:"enum_values,array" json:"values"` //in sort order
+}
+
+//Sequence is description of a sequence
+type Sequence struct {
+	Name        string `sql:"name" json:"name"`
+	DataType    string `sql:"data_type" json:"data_type"`
+	Start       int64  `sql:"start" json:"start"`
+	Min         int64  `sql:"min" json:"min"`
+	Max         int64  `sql:"max" json:"max"`
+	Increment   int64  `sql:"increment" json:"increment"`
+	Cycle       bool   `sql:"cycle" json:"cycle"`
+	OwnerTable  string `sql:"owner_table" json:"owner_table"` //table of serial or identity column owning the sequence
+	OwnerColumn string `sql:"owner_column" json:"owner_column"`
+}
+
+//Trigger is description of a table trigger
+type Trigger struct {
+	Table      string   `sql:"table_name" json:"table"`
+	Name       string   `sql:"name" json:"name"`
+	Timing     string   `sql:"timing" json:"timing"`       //BEFORE, AFTER or INSTEAD OF
+	Events     []string `sql:"events,array" json:"events"` //INSERT, UPDATE, DELETE or TRUNCATE
+	Function   string   `sql:"function" json:"function"`
+	Definition string   `sql:"definition" json:"definition"`
+}
+
+//View is description of a view or materialized view
+type View struct {
+	Name         string `sql:"name" json:"name"`
+	Materialized bool   `sql:"materialized" json:"materialized"`
+	Definition   string `sql:"definition" json:"definition"`
+}
+
+//Enums will return given enum types of schema.
+//All the enum types of schema are returned if no enum is given
+func Enums(db orm.DB, schema string, enums ...string) (enum []Enum, err error) {
+	where, params := getFilter("t.typname", typeVisible, schema, enums)
+	query := `SELECT t.typname AS name,
+	array_agg(e.enumlabel::text ORDER BY e.enumsortorder) AS enum_values
+	FROM pg_type t
+	JOIN pg_enum e ON e.enumtypid = t.oid
+	JOIN pg_namespace n ON n.oid = t.typnamespace
+	WHERE ` + where + `
+	GROUP BY t.typname
+	ORDER BY t.typname;`
+	_, err = db.Query(&enum, query, params...)
+	return
+}
+
+//Sequences will return all the sequences of schema
+func Sequences(db orm.DB, schema string) (sequence []Sequence, err error) {
+	where, params := getFilter("", tableVisible, schema, nil)
+	query := `SELECT c.relname AS name, format_type(s.seqtypid, NULL) AS data_type,
+	s.seqstart AS start, s.seqmin AS min, s.seqmax AS max, s.seqincrement AS increment,
+	s.seqcycle AS cycle, oc.relname AS owner_table, a.attname AS owner_column
+	FROM pg_sequence s
+	JOIN pg_class c ON c.oid = s.seqrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	LEFT JOIN pg_depend d ON d.classid = 'pg_class'::regclass AND d.objid = c.oid
+	AND d.refclassid = 'pg_class'::regclass AND d.deptype IN ('a', 'i')
+	LEFT JOIN pg_class oc ON oc.oid = d.refobjid
+	LEFT JOIN pg_attribute a ON a.attrelid = d.refobjid AND a.attnum = d.refobjsubid
+	WHERE ` + where + `
+	ORDER BY c.relname;`
+	_, err = db.Query(&sequence, query, params...)
+	return
+}
+
+//Triggers will return user defined triggers of given tables of schema.
+//tgtype bits are 2 before, 64 instead of, 4 insert, 8 delete, 16 update and 32 truncate.
+//Triggers of all the tables of schema are returned if no table is given
+func Triggers(db orm.DB, schema string, tables ...string) (trigger []Trigger, err error) {
+	where, params := getFilter("c.relname", tableVisible, schema, tables)
+	query := `SELECT c.relname AS table_name, t.tgname AS name,
+	CASE WHEN t.tgtype & 2 > 0 THEN 'BEFORE' WHEN t.tgtype & 64 > 0 THEN 'INSTEAD OF'
+	ELSE 'AFTER' END AS timing,
+	array_remove(ARRAY[
+		CASE WHEN t.tgtype & 4 > 0 THEN 'INSERT' END,
+		CASE WHEN t.tgtype & 16 > 0 THEN 'UPDATE' END,
+		CASE WHEN t.tgtype & 8 > 0 THEN 'DELETE' END,
+		CASE WHEN t.tgtype & 32 > 0 THEN 'TRUNCATE' END
+	]::text[], NULL) AS events,
+	p.proname AS function, pg_get_triggerdef(t.oid) AS definition
+	FROM pg_trigger t
+	JOIN pg_class c ON c.oid = t.tgrelid
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	JOIN pg_proc p ON p.oid = t.tgfoid
+	WHERE NOT t.tgisinternal AND ` + where + `
+	ORDER BY c.relname, t.tgname;`
+	_, err = db.Query(&trigger, query, params...)
+	return
+}
+
+//Views will return all the views and materialized views of schema
+func Views(db orm.DB, schema string) (view []View, err error) {
+	where, params := getFilter("", tableVisible, schema, nil)
+	query := `SELECT c.relname AS name, c.relkind = 'm' AS materialized,
+	pg_get_viewdef(c.oid) AS definition
+	FROM pg_class c
+	JOIN pg_namespace n ON n.oid = c.relnamespace
+	WHERE c.relkind IN ('v', 'm') AND ` + where + `
+	ORDER BY c.relname;`
+	_, err = db.Query(&view, query, params...)
+	return
+}
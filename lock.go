@@ -0,0 +1,149 @@
+package shifter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+)
+
+//lockNotAvailable is postgresql error code when lock_timeout is reached
+const lockNotAvailable = "55P03"
+
+//Blocker is a session holding lock on table which will block the alter
+type Blocker struct {
+	Table       string `sql:"table_name" json:"table"`
+	Pid         int    `sql:"pid" json:"pid"`
+	Mode        string `sql:"mode" json:"mode"`
+	State       string `sql:"state" json:"state"`
+	Query       string `sql:"query" json:"query"`
+	XactSeconds int64  `sql:"xact_seconds" json:"xact_seconds"`
+}
+
+//BlockerError is returned when long running sessions are holding lock on table to alter
+type BlockerError struct {
+	Blockers []Blocker
+}
+
+//Error will list all the blocking sessions
+func (e *BlockerError) Error() string {
+	msg := fmt.Sprintf("%v session blocking the alter", len(e.Blockers))
+	for _, b := range e.Blockers {
+		msg += fmt.Sprintf("\n%v pid %v %v %v since %vs: %v",
+			b.Table, b.Pid, b.Mode, b.State, b.XactSeconds, strings.TrimSpace(b.Query))
+	}
+	return msg
+}
+
+// LockTimeout will set lock_timeout of the migration transaction.
+//
+// If lock on table is not acquired within timeout then statement fails
+// instead of blocking all the traffic queued behind it. Zero means no timeout
+func (s *Shifter) LockTimeout(timeout time.Duration) *Shifter {
+	s.lockTimeout = timeout
+	return s
+}
+
+//StatementTimeout will set statement_timeout of the migration transaction. Zero means no timeout
+func (s *Shifter) StatementTimeout(timeout time.Duration) *Shifter {
+	s.stmtTimeout = timeout
+	return s
+}
+
+// Retry will retry the migration transaction max times when lock is not available
+// or table is blocked by other sessions.
+//
+// Wait before each retry is doubled starting from backoff
+func (s *Shifter) Retry(max int, backoff time.Duration) *Shifter {
+	s.retry, s.backoff = max, backoff
+	return s
+}
+
+// CheckBlockers will check pg_locks and pg_stat_activity before altering a table.
+//
+// If any other session whose transaction is running for more than minAge
+// holds lock on the table then BlockerError is returned without executing the alter.
+// Zero disables the check
+func (s *Shifter) CheckBlockers(minAge time.Duration) *Shifter {
+	s.blockerAge = minAge
+	return s
+}
+
+//setTimeout will set lock and statement timeout of transaction
+func (s *Shifter) setTimeout(tx *pg.Tx) (err error) {
+	if s.lockTimeout > 0 {
+		sql := fmt.Sprintf("SET LOCAL lock_timeout = '%dms'", s.lockTimeout/time.Millisecond)
+		if _, err = tx.Exec(sql); err != nil {
+			err = getWrapError("migration", "set lock timeout", sql, err)
+		}
+	}
+	if err == nil && s.stmtTimeout > 0 {
+		sql := fmt.Sprintf("SET LOCAL statement_timeout = '%dms'", s.stmtTimeout/time.Millisecond)
+		if _, err = tx.Exec(sql); err != nil {
+			err = getWrapError("migration", "set statement timeout", sql, err)
+		}
+	}
+	return
+}
+
+//checkBlocker will return BlockerError if table is locked by long running sessions.
+//Each table is checked once in a transaction
+func (s *Shifter) checkBlocker(tx *pg.Tx, step Step) (err error) {
+	if s.blockerAge > 0 && step.Table != "" && step.Lock != NoTableLock {
+		if _, checked := s.checked[step.Table]; checked == false {
+			var blockers []Blocker
+			if blockers, err = getBlockers(tx, step.Table, s.blockerAge); err == nil {
+				if s.checked == nil {
+					s.checked = make(map[string]struct{})
+				}
+				s.checked[step.Table] = struct{}{}
+				if len(blockers) > 0 {
+					err = &BlockerError{Blockers: blockers}
+				}
+			}
+		}
+	}
+	return
+}
+
+//getBlockers will return other sessions holding lock on table
+//whose transaction is running for more than minAge
+func getBlockers(tx *pg.Tx, tableName string, minAge time.Duration) (
+	blockers []Blocker, err error) {
+
+	query := `SELECT c.relname AS table_name, a.pid, l.mode, a.state, a.query,
+	EXTRACT(EPOCH FROM now() - a.xact_start)::bigint AS xact_seconds
+	FROM pg_locks l
+	JOIN pg_class c ON c.oid = l.relation
+	JOIN pg_stat_activity a ON a.pid = l.pid
+	WHERE c.relname = ? AND l.pid <> pg_backend_pid()
+	AND a.xact_start < now() - ? * interval '1 millisecond'
+	ORDER BY a.xact_start;`
+	if _, err = tx.Query(&blockers, query, tableName,
+		int64(minAge/time.Millisecond)); err != nil {
+		err = getWrapError(tableName, "blockers", query, err)
+	}
+	return
+}
+
+//isRetryable will check migration can be retried
+//as it failed due to lock not available or blocking sessions
+func isRetryable(err error) (flag bool) {
+	for unwrap := true; unwrap; {
+		switch wErr := err.(type) {
+		case *wrapError:
+			err = wErr.err
+		case *StatementError:
+			err = wErr.Err
+		default:
+			unwrap = false
+		}
+	}
+	if _, ok := err.(*BlockerError); ok {
+		flag = true
+	} else if pgErr, ok := err.(pg.Error); ok {
+		flag = pgErr.Field('C') == lockNotAvailable
+	}
+	return
+}
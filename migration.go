@@ -0,0 +1,205 @@
+package shifter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/util"
+)
+
+//migrationTable keeps the run once migrations applied on database
+const migrationTable = "shifter_migration"
+
+//Migration is run once data migration of table returned by Migrations() method.
+//Either SQL or Func should be given.
+//Checksum of Func migration covers only its name and version as Go code can't be hashed,
+//so change of Func body is not detected unless Version is changed along with it
+type Migration struct {
+	Name    string
+	SQL     string
+	Func    func(tx *pg.Tx) error
+	Version string //included in checksum, change it along with Func body
+}
+
+//appliedMigration is migration recorded in migration table
+type appliedMigration struct {
+	Name     string `sql:"name"`
+	Checksum string `sql:"checksum"`
+}
+
+// WarnChecksumChange will only warn instead of returning error when checksum of applied migration is changed.
+//
+//default false
+func (s *Shifter) WarnChecksumChange(enable bool) *Shifter {
+	s.warnChecksum = enable
+	return s
+}
+
+//runMigration will apply pending migrations of table in order and record them in migration table.
+//Applied migration is never run again
+func (s *Shifter) runMigration(tx *pg.Tx, tableName string, skipPrompt bool) (err error) {
+	var applied map[string]string
+	migration := s.getMigrationFromMethod(tableName)
+	if len(migration) > 0 {
+		if err = validateMigration(tableName, migration); err != nil {
+			return
+		}
+		if applied, err = s.getAppliedMigration(tx, tableName); err == nil {
+			for _, m := range migration {
+				checksum := getMigrationChecksum(m)
+				if appliedChecksum, exists := applied[m.Name]; exists {
+					if err = s.checkMigrationChecksum(tableName, m.Name, appliedChecksum, checksum); err != nil {
+						break
+					}
+				} else if err = s.applyMigration(tx, tableName, m, checksum, skipPrompt); err != nil {
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+//applyMigration will run the migration and record it in migration table
+func (s *Shifter) applyMigration(tx *pg.Tx, tableName string, m Migration,
+	checksum string, skipPrompt bool) (err error) {
+
+	var isRun bool
+	step := Step{Table: tableName, Op: opRunMigration, Lock: NoTableLock, SQL: m.SQL}
+	if m.Func != nil {
+		step.SQL = fmt.Sprintf("-- %v migration %v by Go func\n", tableName, m.Name)
+	}
+	if m.Func == nil || s.plan {
+		isRun, err = s.execByChoice(tx, step, skipPrompt)
+	} else if s.getChoice(step.SQL, skipPrompt) == util.Yes {
+		isRun, err = true, m.Func(tx)
+		s.catalog.reset()
+	}
+	if err != nil {
+		err = getWrapError(tableName, opRunMigration+" "+m.Name, step.SQL, err)
+	} else if isRun && s.plan == false {
+		sql := fmt.Sprintf("INSERT INTO %v (table_name, name, checksum) VALUES (?, ?, ?);", migrationTable)
+		if _, err = tx.Exec(sql, tableName, m.Name, checksum); err != nil {
+			err = getWrapError(tableName, "record migration", sql, err)
+		}
+	}
+	return
+}
+
+//validateMigration will check migration names are given and unique
+func validateMigration(tableName string, migration []Migration) (err error) {
+	name := make(map[string]struct{})
+	for _, m := range migration {
+		if _, exists := name[m.Name]; exists || m.Name == "" {
+			err = fmt.Errorf("%v migration name %q is empty or duplicate", tableName, m.Name)
+			break
+		} else if (m.SQL == "") == (m.Func == nil) {
+			err = fmt.Errorf("%v migration %v should have either SQL or Func", tableName, m.Name)
+			break
+		}
+		name[m.Name] = struct{}{}
+	}
+	return
+}
+
+//checkMigrationChecksum will return error if applied migration is changed
+func (s *Shifter) checkMigrationChecksum(tableName, name, appliedChecksum, checksum string) (err error) {
+	if appliedChecksum != checksum {
+		msg := fmt.Sprintf("%v migration %v is changed after it was applied", tableName, name)
+		if s.warnChecksum {
+			fmt.Println("Warning:", msg)
+		} else {
+			err = errors.New(msg)
+		}
+	}
+	return
+}
+
+//baselineMigration will record migrations of newly created table as applied
+//as the table is created with latest struct
+func (s *Shifter) baselineMigration(tx *pg.Tx, tableName string) (err error) {
+	migration := s.getMigrationFromMethod(tableName)
+	if len(migration) > 0 {
+		if err = createMigrationTable(tx); err == nil {
+			sql := fmt.Sprintf("INSERT INTO %v (table_name, name, checksum) VALUES (?, ?, ?) "+
+				"ON CONFLICT DO NOTHING;", migrationTable)
+			for _, m := range migration {
+				if _, err = tx.Exec(sql, tableName, m.Name, getMigrationChecksum(m)); err != nil {
+					err = getWrapError(tableName, "record migration", sql, err)
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+//getAppliedMigration will return checksum of applied migrations of table.
+//Migration table is created if not exists
+func (s *Shifter) getAppliedMigration(tx *pg.Tx, tableName string) (
+	applied map[string]string, err error) {
+
+	var migration []appliedMigration
+	applied = make(map[string]string)
+	//in plan mode migration table is not created so all migrations are pending
+	if s.plan && tableExists(tx, migrationTable) == false {
+		return
+	}
+	if err = createMigrationTable(tx); err == nil {
+		sql := fmt.Sprintf("SELECT name, checksum FROM %v WHERE table_name = ?;", migrationTable)
+		if _, err = tx.Query(&migration, sql, tableName); err == nil {
+			for _, m := range migration {
+				applied[m.Name] = m.Checksum
+			}
+		} else {
+			err = getWrapError(tableName, "applied migration", sql, err)
+		}
+	}
+	return
+}
+
+//createMigrationTable will create migration table if not exists
+func createMigrationTable(tx *pg.Tx) (err error) {
+	sql := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v (
+		table_name VARCHAR(63) NOT NULL,
+		name VARCHAR(255) NOT NULL,
+		checksum VARCHAR(64) NOT NULL,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+		PRIMARY KEY (table_name, name)
+	);`, migrationTable)
+	if _, err = tx.Exec(sql); err != nil {
+		err = getWrapError(migrationTable, "create table", sql, err)
+	}
+	return
+}
+
+//getMigrationChecksum will return checksum of migration.
+//For Go func only name and version are considered.
+//Version is added only if given to keep checksum of migrations applied without it
+func getMigrationChecksum(m Migration) string {
+	data := m.Name + "\n" + m.SQL
+	if m.Version != "" {
+		data += "\nversion " + m.Version
+	}
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
+
+//getMigrationFromMethod will return migrations from Migrations() method associated to table structure
+func (s *Shifter) getMigrationFromMethod(tableName string) (migration []Migration) {
+	if dbModel, exists := s.table[tableName]; exists {
+		refObj := reflect.ValueOf(dbModel)
+		m := refObj.MethodByName("Migrations")
+		if m.IsValid() && m.Type().NumIn() == 0 {
+			out := m.Call([]reflect.Value{})
+			if len(out) > 0 && out[0].Kind() == reflect.Slice {
+				migration, _ = out[0].Interface().([]Migration)
+			}
+		}
+	}
+	return
+}
@@ -8,8 +8,12 @@ type ColSchema struct {
 	ColumnDefault     string `sql:"column_default"`
 	DataType          string `sql:"data_type"`
 	UdtName           string `sql:"udt_name"`
+	FullType          string `sql:"full_type"`
 	IsNullable        string `sql:"is_nullable"`
 	CharMaxLen        string `sql:"character_maximum_length"`
+	NumericPrecision  string `sql:"numeric_precision"`
+	NumericScale      string `sql:"numeric_scale"`
+	DatetimePrecision string `sql:"datetime_precision"`
 	ConstraintType    string `sql:"constraint_type"`
 	ConstraintName    string `sql:"constraint_name"`
 	IsDeferrable      string `sql:"is_deferrable"`
@@ -18,12 +22,19 @@ type ColSchema struct {
 	ForeignColumnName string `sql:"foreign_column_name"`
 	UpdateType        string `sql:"confupdtype"`
 	DeleteType        string `sql:"confdeltype"`
+	NotValid          bool   `sql:"not_valid"`
 	SeqName           string `sql:"seq_name"`
 	SeqDataType       string `sql:"seq_data_type"`
+	Identity          string `sql:"identity"`
+	Generated         string `sql:"generated"`
 	Position          int    `sql:"position"`
 	IsFkUnique        bool   `sql:"-"`
 	FkUniqueName      string `sql:"-"`
 	DefaultExists     bool   `sql:"-"`
+	Backfill          string `sql:"-"`
+	Using             string `sql:"-"`
+	UsingFrom         string `sql:"-"`
+	MigrateFrom       string `sql:"-"`
 }
 
 //UKSchema : Unique Schema Model
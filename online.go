@@ -0,0 +1,125 @@
+package shifter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+	"github.com/mayur-tolexo/pg-shifter/util"
+)
+
+// Online will enable online mode to keep big tables writable while altering.
+//
+// NOT NULL is added as CHECK (col IS NOT NULL) NOT VALID which is validated
+// in a separate transaction and then converted to SET NOT NULL without scanning the table.
+// Foreign keys are added as NOT VALID and validated in a separate transaction.
+func (s *Shifter) Online(enable bool) *Shifter {
+	s.online = enable
+	return s
+}
+
+//onlineSetNotNull will add not valid not null check constraint
+//and defer its validation and set not null after commit
+func (s *Shifter) onlineSetNotNull(tx *pg.Tx, schema model.ColSchema,
+	skipPrompt bool) (isAlter bool, err error) {
+
+	checkName := getNotNullCheckName(schema.TableName, schema.ColumnName)
+	sql := getNotNullCheckSQL(schema, checkName)
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opAddConstraint, SQL: sql,
+		checks: []dataCheck{getNullCheck(schema)}}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(schema.TableName, opAddConstraint, sql, err)
+	} else if isAlter {
+		if err = s.deferValidate(tx, schema, checkName); err == nil {
+			err = s.deferStep(tx, getOnlineNotNullStep(schema, checkName))
+		}
+	}
+	return
+}
+
+//getNotNullCheckSQL will return add not valid not null check constraint sql
+func getNotNullCheckSQL(schema model.ColSchema, checkName string) (sql string) {
+	sql = fmt.Sprintf("ALTER TABLE %v DROP CONSTRAINT IF EXISTS %v, "+
+		"ADD CONSTRAINT %v CHECK (%v IS NOT NULL) NOT VALID;\n",
+		schema.TableName, checkName, checkName, schema.ColumnName)
+	return
+}
+
+//getOnlineNotNullStep will return set not null step using valid check constraint
+func getOnlineNotNullStep(schema model.ColSchema, checkName string) Step {
+	//valid check constraint proves not null so set not null will not scan the table
+	sql := getNotNullColSQL(schema.TableName, schema.ColumnName, set) + ";\n"
+	sql += getDropConstraintSQL(schema.TableName, checkName)
+	return Step{Table: schema.TableName, Column: schema.ColumnName, Op: opSetNotNull, SQL: sql}
+}
+
+//getNotValidSQL will return add constraint sql as not valid
+func getNotValidSQL(sql string) string {
+	return strings.TrimSuffix(sql, ";\n") + " NOT VALID;\n"
+}
+
+//deferValidate will defer validation of not valid constraint after commit
+func (s *Shifter) deferValidate(tx *pg.Tx, schema model.ColSchema, constraintName string) (err error) {
+	sql := getValidateConstraintSQL(schema.TableName, constraintName)
+	err = s.deferStep(tx, Step{Table: schema.TableName, Column: schema.ColumnName,
+		Op: opValidateConstraint, Lock: ShareUpdateExclusiveLock, Scan: true, SQL: sql})
+	return
+}
+
+//getValidateConstraintSQL will return validate constraint sql
+func getValidateConstraintSQL(tName, constraintName string) (sql string) {
+	sql = fmt.Sprintf("ALTER TABLE %v VALIDATE CONSTRAINT %v;\n", tName, constraintName)
+	return
+}
+
+//getNotNullCheckName will return name of not null check constraint
+func getNotNullCheckName(tName, cName string) string {
+	return fmt.Sprintf("%v_%v_%v", tName, cName, notNullSuffix)
+}
+
+//deferStep will keep step to execute in its own transaction after commit.
+//In plan mode step is recorded directly
+func (s *Shifter) deferStep(tx *pg.Tx, step Step) (err error) {
+	if s.plan {
+		_, err = s.execByChoice(tx, step, true)
+	} else {
+		s.deferred = append(s.deferred, step)
+	}
+	return
+}
+
+//runDeferred will execute deferred steps each in its own transaction.
+//If a step is declined in prompt then it and the steps after it are kept pending in checkpoint table
+func (s *Shifter) runDeferred(conn *pg.DB, skipPrompt bool) (err error) {
+	deferred := s.deferred
+	s.deferred = nil
+	for i, step := range deferred {
+		if skipPrompt == false && s.plan == false && s.getChoice(step.SQL, false) != util.Yes {
+			fmt.Printf("%v deferred step kept pending, call Resume() or rerun alter to complete\n",
+				len(deferred)-i)
+			break
+		} else if step.run != nil {
+			if err = step.run(conn); err == nil {
+				err = s.retryTx(conn, func(tx *pg.Tx) error {
+					return doneCheckpoint(tx, step)
+				})
+			}
+		} else {
+			err = s.retryTx(conn, func(tx *pg.Tx) (err error) {
+				if _, err = s.execByChoice(tx, step, true); err != nil {
+					err = getWrapError(step.Table, step.Op, step.SQL, err)
+				} else {
+					err = doneCheckpoint(tx, step)
+				}
+				return
+			})
+		}
+		if err != nil {
+			err = fmt.Errorf("%v\n%v deferred step not executed, call Resume() or rerun alter to complete",
+				err.Error(), len(deferred)-i)
+			break
+		}
+	}
+	return
+}
@@ -0,0 +1,196 @@
+package shifter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/flaw"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//lock level taken on table by a step
+const (
+	NoTableLock              = "NONE"
+	ShareUpdateExclusiveLock = "SHARE UPDATE EXCLUSIVE"
+	RowExclusiveLock         = "ROW EXCLUSIVE"
+	ShareLock                = "SHARE"
+	ShareRowExclusiveLock    = "SHARE ROW EXCLUSIVE"
+	AccessExclusiveLock      = "ACCESS EXCLUSIVE"
+)
+
+//stepLock is default lock level of step by its operation
+var stepLock = map[string]string{
+	opAddEnumValue:  NoTableLock,
+	opDropEnumValue: NoTableLock,
+	opDropEnum:      NoTableLock,
+	opCreateEnum:    NoTableLock,
+}
+
+//volatileFunc are the default functions which are evaluated per row.
+//Adding column with such default will rewrite the table
+var volatileFunc = []string{"nextval(", "random(", "gen_random_uuid(",
+	"uuid_generate_", "clock_timestamp(", "timeofday("}
+
+//tableStat is estimated table size from pg_class
+type tableStat struct {
+	RowCount int64 `sql:"row_count"`
+	Size     int64 `sql:"size"`
+}
+
+//LintRule is a check on planned step. If rule returns error then step is rejected
+type LintRule func(step Step) error
+
+// Lint will add lint rules which are checked on every planned step.
+//
+// If any rule returns error then step is not executed
+// and error listing all the rejected steps is returned as PolicyError.
+func (s *Shifter) Lint(rules ...LintRule) *Shifter {
+	s.lint = append(s.lint, rules...)
+	return s
+}
+
+//NoRewrite will reject steps which rewrite table having more than maxRows rows
+func NoRewrite(maxRows int64) LintRule {
+	return func(step Step) (err error) {
+		if step.Rewrite && step.RowCount > maxRows {
+			err = fmt.Errorf("table rewrite of %v rows not allowed (max %v)", step.RowCount, maxRows)
+		}
+		return
+	}
+}
+
+//NoFullScan will reject steps which scan table having more than maxRows rows
+//while holding access exclusive lock
+func NoFullScan(maxRows int64) LintRule {
+	return func(step Step) (err error) {
+		if (step.Scan || step.Rewrite) && step.Lock == AccessExclusiveLock &&
+			step.RowCount > maxRows {
+			err = fmt.Errorf("full scan of %v rows under %v lock not allowed (max %v)",
+				step.RowCount, step.Lock, maxRows)
+		}
+		return
+	}
+}
+
+// PlanTable will return the steps which AlterTable will execute without executing them.
+//
+// Parameters
+//  conn: postgresql connection
+//  model: struct pointer or string (table name)
+// Each step is annotated with lock level, table rewrite, full scan
+// and estimated row count/size of the table.
+// Steps blocked by policy or lint rules are returned with Blocked flag and Reason.
+func (s *Shifter) PlanTable(conn *pg.DB, model interface{}) (plan []Step, err error) {
+	var tableName string
+	if tableName, err = s.getTableName(model); err == nil {
+		plan, err = s.planTx(conn, func(tx *pg.Tx) (err error) {
+			err = s.alterTable(tx, tableName, true)
+			return
+		})
+	}
+	return
+}
+
+//PlanAllTable will return the steps which AlterAllTable will execute without executing them
+//before calling it you need to set the table model in shifter using SetTableModels()
+func (s *Shifter) PlanAllTable(conn *pg.DB) (plan []Step, err error) {
+	plan, err = s.planTx(conn, func(tx *pg.Tx) (err error) {
+		for _, tableName := range s.getTableNames() {
+			if err = s.alterTable(tx, tableName, true); err != nil {
+				break
+			}
+		}
+		return
+	})
+	return
+}
+
+//planTx will run fn in plan mode and return recorded steps.
+//Transaction is always rolled back
+func (s *Shifter) planTx(conn *pg.DB, fn func(tx *pg.Tx) error) (plan []Step, err error) {
+	var tx *pg.Tx
+	if tx, err = conn.Begin(); err == nil {
+		s.plan, s.steps, s.blocked, s.failed, s.tableStat = true, nil, nil, nil, nil
+		if err = fn(tx); err == nil {
+			plan = s.steps
+		}
+		s.plan, s.steps, s.blocked, s.failed = false, nil, nil, nil
+		tx.Rollback()
+	} else {
+		err = flaw.TxError(err)
+	}
+	return
+}
+
+//annotateStep will set lock level and table size of step
+func (s *Shifter) annotateStep(tx *pg.Tx, step *Step) (err error) {
+	if step.Lock == "" {
+		if step.Lock = stepLock[step.Op]; step.Lock == "" {
+			step.Lock = AccessExclusiveLock
+		}
+	}
+	if s.plan || len(s.lint) > 0 {
+		var stat tableStat
+		if stat, err = s.getTableStat(tx, step.Table); err == nil {
+			step.RowCount, step.Size = stat.RowCount, stat.Size
+		}
+	}
+	return
+}
+
+//getTableStat will return estimated row count and size of table from pg_class.
+//Table which is never analyzed has reltuples -1 (0 before postgresql 14) so its row count
+//is estimated from heap size as max tuples it can have, a tuple takes at least 28 bytes with its line pointer.
+//Stats of table which doesn't exist yet are zero. Stats are cached for the run
+func (s *Shifter) getTableStat(tx *pg.Tx, tableName string) (stat tableStat, err error) {
+	var exists bool
+	if stat, exists = s.tableStat[tableName]; exists == false {
+		var stats []tableStat
+		query := `SELECT CASE WHEN c.reltuples > 0 OR (c.reltuples = 0 AND c.relpages > 0) THEN c.reltuples::bigint
+		ELSE pg_relation_size(c.oid) / 28 END AS row_count,
+		pg_total_relation_size(c.oid) AS size
+		FROM pg_class c JOIN pg_namespace n ON n.oid = c.relnamespace
+		WHERE c.relname = ? AND c.relkind IN ('r','p')
+		AND n.nspname = ANY(current_schemas(false)) LIMIT 1;`
+		if _, err = tx.Query(&stats, query, tableName); err == nil {
+			if len(stats) > 0 {
+				stat = stats[0]
+			}
+			if s.tableStat == nil {
+				s.tableStat = make(map[string]tableStat)
+			}
+			s.tableStat[tableName] = stat
+		} else {
+			err = getWrapError(tableName, "table stat", query, err)
+		}
+	}
+	return
+}
+
+//isVolatileDefault will check column default is evaluated per row
+//due to which adding column will rewrite the table
+func isVolatileDefault(schema model.ColSchema) (flag bool) {
+	if schema.SeqName != "" || isSerialType(schema.DataType) || schema.Identity != "" ||
+		schema.Generated != "" {
+		flag = true
+	} else {
+		def := strings.ToLower(schema.ColumnDefault)
+		for _, fn := range volatileFunc {
+			if strings.Contains(def, fn) {
+				flag = true
+				break
+			}
+		}
+	}
+	return
+}
+
+//getConstraintLock will return lock level taken by adding constraint
+func getConstraintLock(schema model.ColSchema) (lock string) {
+	lock = AccessExclusiveLock
+	if schema.ConstraintType == foreignKey {
+		lock = ShareRowExclusiveLock
+	}
+	return
+}
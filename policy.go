@@ -0,0 +1,267 @@
+package shifter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//change class of a step
+const (
+	SafeChange        = "safe"        //change without any risk of data loss
+	RiskyChange       = "risky"       //change which can fail or rewrite the table but won't lose data
+	DestructiveChange = "destructive" //change which can lose data
+)
+
+//Step is a single planned schema change
+type Step struct {
+	Table      string `json:"table"`
+	Column     string `json:"column,omitempty"`
+	Op         string `json:"op"`
+	Class      string `json:"class"`
+	Lock       string `json:"lock"`
+	Rewrite    bool   `json:"rewrite"`
+	Scan       bool   `json:"scan"`
+	RowCount   int64  `json:"row_count"`
+	Size       int64  `json:"size"`
+	Blocked    bool   `json:"blocked,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+	SQL        string `json:"sql"`
+	checks     []dataCheck
+	run        func(conn *pg.DB) error
+	backfill   string
+	checkpoint int
+}
+
+//PolicyError is returned when steps are blocked by destructive policy or lint rules
+type PolicyError struct {
+	Blocked []Step
+}
+
+//Error will list all the blocked steps
+func (e *PolicyError) Error() string {
+	msg := fmt.Sprintf("%v change blocked by policy", len(e.Blocked))
+	for _, step := range e.Blocked {
+		msg += fmt.Sprintf("\n%v %v", step.Table, step.Op)
+		if step.Column != "" {
+			msg += " " + step.Column
+		}
+		msg += ": " + step.Reason
+		msg += "\nSQL: " + strings.TrimSpace(step.SQL)
+	}
+	return msg
+}
+
+//stepClass is default class of step by its operation
+var stepClass = map[string]string{
+	opAddColumn:        SafeChange,
+	opDropColumn:       DestructiveChange,
+	opModifyDataType:   DestructiveChange,
+	opModifyDefault:    SafeChange,
+	opSetNotNull:       RiskyChange,
+	opDropNotNull:      SafeChange,
+	opAddConstraint:    RiskyChange,
+	opDropConstraint:   DestructiveChange,
+	opModifyDeferrable: SafeChange,
+	opModifyIdentity:   RiskyChange,
+	opModifyGenerated:  RiskyChange,
+	opAddCompositeUK:   RiskyChange,
+	opDropCompositeUK:  DestructiveChange,
+	opAddEnumValue:     SafeChange,
+	opDropEnumValue:    DestructiveChange,
+	opDropEnum:         DestructiveChange,
+	opDropTable:        DestructiveChange,
+	opSoftDropColumn:   SafeChange,
+	opSoftDropTable:    SafeChange,
+	opPurgeColumn:      DestructiveChange,
+	opPurgeTable:       DestructiveChange,
+}
+
+//typeWidening is lossless data type conversion.
+//If converted type is true then its metadata only change else table will be rewritten
+var typeWidening = map[string]map[string]bool{
+	"smallint":                    {"integer": false, "bigint": false, "numeric": false, "real": false, "double precision": false},
+	"integer":                     {"bigint": false, "numeric": false, "double precision": false},
+	"bigint":                      {"numeric": false},
+	"real":                        {"double precision": false},
+	"character":                   {"character varying": false, "text": false},
+	"character varying":           {"text": true},
+	"text":                        {"character varying": true},
+	"citext":                      {"text": true},
+	"date":                        {"timestamp without time zone": false, "timestamp with time zone": false},
+	"timestamp without time zone": {"timestamp with time zone": false},
+	"time without time zone":      {"time with time zone": false},
+	"json":                        {"jsonb": false, "text": false},
+	"jsonb":                       {"json": false, "text": false},
+}
+
+// GuardDestructive will enable destructive change policy.
+//
+// If enabled then destructive changes like drop column/table/constraint/enum,
+// narrowing varchar or data type change which can lose data will not be executed
+// unless allowed by AllowDestructive(). Error listing all the blocked changes will be returned.
+func (s *Shifter) GuardDestructive(enable bool) *Shifter {
+	s.guard = enable
+	return s
+}
+
+// AllowDestructive will allow destructive changes on given tables
+// when GuardDestructive is enabled.
+//
+// Approval is for the next run only (AlterAllTable, DropTable, ...) and is cleared after it.
+// If no table is given then destructive changes are allowed on all tables for the run
+func (s *Shifter) AllowDestructive(tables ...string) *Shifter {
+	if len(tables) == 0 {
+		s.allowAll = true
+	}
+	if s.allow == nil {
+		s.allow = make(map[string]struct{})
+	}
+	for _, tableName := range tables {
+		s.allow[tableName] = struct{}{}
+	}
+	return s
+}
+
+//endRun will clear approvals given by AllowDestructive() once the outermost run is completed
+func (s *Shifter) endRun() {
+	if s.runDepth--; s.runDepth == 0 {
+		s.allowAll, s.allow = false, nil
+	}
+}
+
+//guardStep will set class of step and return true if step is blocked by policy or lint rules.
+//Blocked steps are kept in shifter which will be returned as PolicyError
+func (s *Shifter) guardStep(step *Step) (blocked bool) {
+	if step.Class == "" {
+		if step.Class = stepClass[step.Op]; step.Class == "" {
+			step.Class = SafeChange
+		}
+	}
+	if s.guard && step.Class == DestructiveChange && s.allowAll == false {
+		if _, allowed := s.allow[step.Table]; allowed == false {
+			step.Reason = "destructive change not allowed"
+		}
+	}
+	if step.Reason == "" {
+		for _, rule := range s.lint {
+			if err := rule(*step); err != nil {
+				step.Reason = err.Error()
+				break
+			}
+		}
+	}
+	if step.Reason != "" {
+		blocked = true
+		step.Blocked = true
+		s.blocked = append(s.blocked, *step)
+	}
+	return
+}
+
+//getBlockedError will return policy error if any step is blocked
+//and reset the blocked steps for next run
+func (s *Shifter) getBlockedError() (err error) {
+	if len(s.blocked) > 0 {
+		err = &PolicyError{Blocked: s.blocked}
+		s.blocked = nil
+	}
+	return
+}
+
+//getTypeChangeClass will return class of data type change.
+//Widening the type is safe/risky while narrowing or any other conversion is destructive
+func getTypeChangeClass(tSchema, sSchema model.ColSchema) (class string) {
+	tType, sType := getBaseType(tSchema), getBaseType(sSchema)
+	class = DestructiveChange
+
+	if tType == sType {
+		if isLenWidening(tSchema, sSchema) && isPrecisionWidening(tSchema, sSchema) {
+			class = SafeChange
+			//increasing precision without changing scale doesn't rewrite the table
+			if tType != "character varying" && tType != "bit varying" &&
+				(getTypePrecision(tSchema)+getTypePrecision(sSchema) == "" ||
+					tSchema.NumericScale != sSchema.NumericScale) {
+				class = RiskyChange
+			}
+		}
+	} else if metaOnly, exists := typeWidening[tType][sType]; exists {
+		if isLenWidening(tSchema, sSchema) {
+			class = RiskyChange
+			if metaOnly {
+				class = SafeChange
+			}
+		}
+	} else if sType == "text" {
+		class = RiskyChange
+	}
+	return
+}
+
+//isLenWidening will check struct column length is not less than table column length
+func isLenWidening(tSchema, sSchema model.ColSchema) (flag bool) {
+	if sSchema.CharMaxLen == "" {
+		flag = true
+	} else if tSchema.CharMaxLen != "" {
+		tLen, tErr := strconv.Atoi(tSchema.CharMaxLen)
+		sLen, sErr := strconv.Atoi(sSchema.CharMaxLen)
+		flag = tErr == nil && sErr == nil && sLen >= tLen
+	}
+	return
+}
+
+//isPrecisionWidening will check struct column precision and scale can hold all the table column values.
+//Numeric integer digits and scale shouldn't decrease and time precision shouldn't decrease
+//where undeclared precision is unlimited for numeric and 6 for time types
+func isPrecisionWidening(tSchema, sSchema model.ColSchema) (flag bool) {
+	if sSchema.NumericPrecision != "" {
+		if tSchema.NumericPrecision != "" {
+			tPrec, tErr := strconv.Atoi(tSchema.NumericPrecision)
+			tScale, tsErr := strconv.Atoi(tSchema.NumericScale)
+			sPrec, sErr := strconv.Atoi(sSchema.NumericPrecision)
+			sScale, ssErr := strconv.Atoi(sSchema.NumericScale)
+			flag = tErr == nil && tsErr == nil && sErr == nil && ssErr == nil &&
+				sScale >= tScale && sPrec-sScale >= tPrec-tScale
+		}
+	} else if sSchema.DatetimePrecision != "" {
+		tDatetimePrec := "6"
+		if tSchema.DatetimePrecision != "" {
+			tDatetimePrec = tSchema.DatetimePrecision
+		}
+		tPrec, tErr := strconv.Atoi(tDatetimePrec)
+		sPrec, sErr := strconv.Atoi(sSchema.DatetimePrecision)
+		flag = tErr == nil && sErr == nil && sPrec >= tPrec
+	} else {
+		flag = true
+	}
+	return
+}
+
+//getBaseType will return data type of schema without length.
+//serial types are resolved to their integer type
+func getBaseType(schema model.ColSchema) (dType string) {
+	dType = schema.DataType
+	if schema.SeqName != "" && schema.SeqDataType != "" {
+		dType = schema.SeqDataType
+	} else if schema.DataType == userDefined {
+		dType = schema.UdtName
+	} else if schema.DataType == arrayType {
+		//element type without its length like character varying[]
+		dType = typeModRegex.ReplaceAllString(getStructDataType(schema), "")
+	}
+	switch dType {
+	case "serial", "serial4":
+		dType = "integer"
+	case "bigserial", "serial8":
+		dType = "bigint"
+	case "smallserial", "serial2":
+		dType = "smallint"
+	}
+	if alias, exists := pgAlias[dType]; exists {
+		dType = alias
+	}
+	return
+}
@@ -0,0 +1,206 @@
+package shifter
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+)
+
+//pre check names
+const (
+	NullCheck      = "null"      //null values while adding not null/primary key
+	DuplicateCheck = "duplicate" //duplicate values while adding unique/primary key
+	OrphanCheck    = "orphan"    //values missing in foreign table while adding foreign key
+	LengthCheck    = "length"    //values longer than new length while narrowing column
+	PrecisionCheck = "precision" //values out of range of new numeric precision and scale
+	EnumCheck      = "enum"      //values not in enum while converting column to enum
+)
+
+//sampleLimit is max number of offending values returned by pre check
+const sampleLimit = 5
+
+//dataCheck is pre check query of a step
+type dataCheck struct {
+	name   string
+	column string
+	count  string
+	sample string
+}
+
+//CheckFailure is offending data found by pre check
+type CheckFailure struct {
+	Table  string   `json:"table"`
+	Column string   `json:"column"`
+	Check  string   `json:"check"`
+	Count  int64    `json:"count"`
+	Sample []string `json:"sample"`
+	SQL    string   `json:"sql"`
+}
+
+//PreCheckError is returned when data will not satisfy the planned change
+type PreCheckError struct {
+	Failures []CheckFailure
+}
+
+//Error will list all the failed pre checks with sample values
+func (e *PreCheckError) Error() string {
+	msg := fmt.Sprintf("%v pre check failed", len(e.Failures))
+	for _, f := range e.Failures {
+		msg += fmt.Sprintf("\n%v.%v %v check: %v rows, sample: %v",
+			f.Table, f.Column, f.Check, f.Count, strings.Join(f.Sample, " | "))
+	}
+	return msg
+}
+
+// PreCheck will enable/disable data pre checks. Default enabled.
+//
+// Before adding not null, unique/primary key, foreign key, narrowing column length
+// or converting column to enum, data is checked and if offending rows are found
+// then that change is not executed and PreCheckError with sample values is returned.
+func (s *Shifter) PreCheck(enable bool) *Shifter {
+	s.skipPreCheck = enable == false
+	return s
+}
+
+//preCheck will run data checks of step and return true if any check failed.
+//Failures are kept in shifter which will be returned as PreCheckError
+func (s *Shifter) preCheck(tx *pg.Tx, step *Step) (failed bool, err error) {
+	if s.skipPreCheck == false {
+		for _, check := range step.checks {
+			var count int64
+			if _, err = tx.Query(pg.Scan(&count), check.count); err != nil {
+				err = getWrapError(step.Table, check.name+" check", check.count, err)
+				break
+			} else if count > 0 {
+				failure := CheckFailure{Table: step.Table, Column: check.column,
+					Check: check.name, Count: count, SQL: step.SQL}
+				if _, err = tx.Query(&failure.Sample, check.sample); err != nil {
+					err = getWrapError(step.Table, check.name+" check", check.sample, err)
+					break
+				}
+				failed = true
+				step.Blocked = true
+				step.Reason = fmt.Sprintf("%v check failed on %v rows", check.name, count)
+				s.failed = append(s.failed, failure)
+			}
+		}
+	}
+	return
+}
+
+//getPreCheckError will return pre check error if any check failed
+//and reset the failures for next run
+func (s *Shifter) getPreCheckError() (err error) {
+	if len(s.failed) > 0 {
+		err = &PreCheckError{Failures: s.failed}
+		s.failed = nil
+	}
+	return
+}
+
+//getNullCheck will return check of null values in column
+func getNullCheck(schema model.ColSchema) dataCheck {
+	where := fmt.Sprintf("FROM %v t WHERE t.%v IS NULL", schema.TableName, schema.ColumnName)
+	return dataCheck{
+		name:   NullCheck,
+		column: schema.ColumnName,
+		count:  "SELECT count(*) " + where,
+		sample: fmt.Sprintf("SELECT t::text %v LIMIT %v", where, sampleLimit),
+	}
+}
+
+//getDuplicateCheck will return check of duplicate values in columns
+func getDuplicateCheck(tName string, columns []string) dataCheck {
+	cols := strings.Join(columns, ", ")
+	group := fmt.Sprintf("FROM %v WHERE %v IS NOT NULL GROUP BY %v HAVING count(*) > 1",
+		tName, strings.Join(columns, " IS NOT NULL AND "), cols)
+	return dataCheck{
+		name:   DuplicateCheck,
+		column: cols,
+		count:  fmt.Sprintf("SELECT count(*) FROM (SELECT 1 %v) d", group),
+		sample: fmt.Sprintf("SELECT concat_ws(', ', %v) || ' (' || count(*) || ' rows)' %v LIMIT %v",
+			cols, group, sampleLimit),
+	}
+}
+
+//getOrphanCheck will return check of values missing in foreign table
+func getOrphanCheck(schema model.ColSchema) dataCheck {
+	where := fmt.Sprintf("FROM %v t WHERE t.%v IS NOT NULL AND NOT EXISTS "+
+		"(SELECT 1 FROM %v f WHERE f.%v = t.%v)", schema.TableName, schema.ColumnName,
+		schema.ForeignTableName, schema.ForeignColumnName, schema.ColumnName)
+	return dataCheck{
+		name:   OrphanCheck,
+		column: schema.ColumnName,
+		count:  "SELECT count(*) " + where,
+		sample: fmt.Sprintf("SELECT DISTINCT t.%v::text %v LIMIT %v", schema.ColumnName, where, sampleLimit),
+	}
+}
+
+//getLengthCheck will return check of values longer than struct column length
+func getLengthCheck(schema model.ColSchema) dataCheck {
+	where := fmt.Sprintf("FROM %v t WHERE length(t.%v::text) > %v",
+		schema.TableName, schema.ColumnName, schema.CharMaxLen)
+	return dataCheck{
+		name:   LengthCheck,
+		column: schema.ColumnName,
+		count:  "SELECT count(*) " + where,
+		sample: fmt.Sprintf("SELECT DISTINCT t.%v::text %v LIMIT %v", schema.ColumnName, where, sampleLimit),
+	}
+}
+
+//getPrecisionCheck will return check of values which don't fit struct column numeric precision and scale.
+//Value is rounded to the scale before comparing with the max integer digits
+func getPrecisionCheck(schema model.ColSchema) dataCheck {
+	where := fmt.Sprintf("FROM %v t WHERE abs(round(t.%v::numeric, %v)) >= 10 ^ (%v - %v)",
+		schema.TableName, schema.ColumnName, schema.NumericScale, schema.NumericPrecision, schema.NumericScale)
+	return dataCheck{
+		name:   PrecisionCheck,
+		column: schema.ColumnName,
+		count:  "SELECT count(*) " + where,
+		sample: fmt.Sprintf("SELECT DISTINCT t.%v::text %v LIMIT %v", schema.ColumnName, where, sampleLimit),
+	}
+}
+
+//getEnumCheck will return check of values which are not in enum
+func getEnumCheck(schema model.ColSchema, enumValue []string) dataCheck {
+	where := fmt.Sprintf("FROM %v t WHERE t.%v IS NOT NULL AND t.%v::text NOT IN ('%v')",
+		schema.TableName, schema.ColumnName, schema.ColumnName, strings.Join(enumValue, "','"))
+	return dataCheck{
+		name:   EnumCheck,
+		column: schema.ColumnName,
+		count:  "SELECT count(*) " + where,
+		sample: fmt.Sprintf("SELECT DISTINCT t.%v::text %v LIMIT %v", schema.ColumnName, where, sampleLimit),
+	}
+}
+
+//getConstraintCheck will return checks of adding pk/uk/fk constraint
+func getConstraintCheck(schema model.ColSchema) (checks []dataCheck) {
+	switch schema.ConstraintType {
+	case primaryKey:
+		checks = append(checks, getNullCheck(schema), getDuplicateCheck(schema.TableName, []string{schema.ColumnName}))
+	case uniqueKey:
+		checks = append(checks, getDuplicateCheck(schema.TableName, []string{schema.ColumnName}))
+	case foreignKey:
+		checks = append(checks, getOrphanCheck(schema))
+	}
+	return
+}
+
+//getTypeChangeCheck will return checks of data type change
+//narrowing column length or converting to enum
+func (s *Shifter) getTypeChangeCheck(tSchema, sSchema model.ColSchema) (checks []dataCheck) {
+	if sSchema.CharMaxLen != "" && isLenWidening(tSchema, sSchema) == false {
+		checks = append(checks, getLengthCheck(sSchema))
+	}
+	if sSchema.NumericPrecision != "" && isPrecisionWidening(tSchema, sSchema) == false {
+		checks = append(checks, getPrecisionCheck(sSchema))
+	}
+	if enumName := getBaseType(sSchema); s.isEnum(sSchema.TableName, enumName) {
+		if enumValue, err := s.getEnum(sSchema.TableName, enumName); err == nil {
+			checks = append(checks, getEnumCheck(sSchema, enumValue))
+		}
+	}
+	return
+}
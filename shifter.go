@@ -3,10 +3,10 @@ package shifter
 import (
 	"fmt"
 	"log"
+	"time"
 
 	"github.com/fatih/color"
 	"github.com/go-pg/pg"
-	"github.com/mayur-tolexo/flaw"
 	m "github.com/mayur-tolexo/pg-shifter/model"
 )
 
@@ -15,14 +15,74 @@ var (
 	enumCreated  = make(map[interface{}]struct{})
 )
 
+//createdState is snapshot of tables and enums marked as created
+//which is restored if transaction creating them is rolled back
+type createdState struct {
+	table map[interface{}]bool
+	enum  map[interface{}]struct{}
+}
+
+//saveCreated will return snapshot of tables and enums marked as created
+func saveCreated() (state createdState) {
+	state.table = make(map[interface{}]bool, len(tableCreated))
+	for k, v := range tableCreated {
+		state.table[k] = v
+	}
+	state.enum = make(map[interface{}]struct{}, len(enumCreated))
+	for k, v := range enumCreated {
+		state.enum[k] = v
+	}
+	return
+}
+
+//restoreCreated will restore tables and enums marked as created from snapshot
+func restoreCreated(state createdState) {
+	tableCreated, enumCreated = state.table, state.enum
+}
+
 //Shifter model contains all the methods to migrate go struct to postgresql
 type Shifter struct {
-	table     map[string]interface{}
-	enumList  map[string][]string
-	hisExists bool
-	logSQL    bool
-	verbose   bool
-	logPath   string
+	table            map[string]interface{}
+	enumList         map[string][]string
+	ignore           map[string][]string
+	allow            map[string]struct{}
+	blocked          []Step
+	steps            []Step
+	deferred         []Step
+	failed           []CheckFailure
+	lint             []LintRule
+	tableStat        map[string]tableStat
+	checked          map[string]struct{}
+	retry            int
+	backoff          time.Duration
+	lockTimeout      time.Duration
+	stmtTimeout      time.Duration
+	blockerAge       time.Duration
+	hisExists        bool
+	guard            bool
+	allowAll         bool
+	runDepth         int
+	choice           map[string]string
+	declined         bool
+	plan             bool
+	online           bool
+	softDrop         bool
+	serialToIdentity bool
+	warnChecksum     bool
+	txMode           string
+	advisoryLock     bool
+	lockHeld         bool
+	lockWait         time.Duration
+	lockKey          int64
+	skipUnchanged    bool
+	force            bool
+	catalog          *catalog
+	skipPreCheck     bool
+	logSQL           bool
+	verbose          bool
+	logPath          string
+	batchSize        int
+	progress         BackfillProgress
 }
 
 func (s *Shifter) logMode(enable bool) {
@@ -64,17 +124,12 @@ func (s *Shifter) Verbose(enable bool) *Shifter {
 //  model: struct pointer or string (table name)
 // if model is table name then need to set shifter SetTableModel() before calling CreateTable()
 func (s *Shifter) CreateTable(conn *pg.DB, model interface{}) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			err = s.createTable(tx, tableName, true)
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -86,17 +141,18 @@ func (s *Shifter) CreateTable(conn *pg.DB, model interface{}) (err error) {
 //  model: struct pointer or string (table name)
 //  skipPrompt: bool (default false | if false then before execution sql it will prompt for confirmation)
 func (s *Shifter) AlterTable(conn *pg.DB, model interface{}, skipPrompt ...bool) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
-			err = s.alterTable(tx, tableName, getSP(skipPrompt))
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+		err = s.withAdvisoryLock(conn, func() (err error) {
+			//pending steps of failed run are completed first
+			if err = s.resume(conn, getSP(skipPrompt), true); err == nil {
+				err = s.runTx(conn, func(tx *pg.Tx) (err error) {
+					err = s.alterTable(tx, tableName, getSP(skipPrompt))
+					return
+				})
+			}
+			return
+		})
 	}
 	return
 }
@@ -107,17 +163,12 @@ func (s *Shifter) AlterTable(conn *pg.DB, model interface{}, skipPrompt ...bool)
 //  model: struct pointer or string (table name)
 //  cascade: if enable then it will drop with cascade
 func (s *Shifter) DropTable(conn *pg.DB, model interface{}, cascade bool) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			err = s.dropTable(tx, tableName, cascade)
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -130,17 +181,12 @@ func (s *Shifter) DropTable(conn *pg.DB, model interface{}, cascade bool) (err e
 //  enumName: enum which you want to create
 // if model is table name then need to set shifter SetTableModel() before calling CreateEnum()
 func (s *Shifter) CreateEnum(conn *pg.DB, model interface{}, enumName string) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			err = s.createEnumByName(tx, tableName, enumName)
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -152,21 +198,16 @@ func (s *Shifter) CreateEnum(conn *pg.DB, model interface{}, enumName string) (e
 //  model: struct pointer or string (table name)
 // if model is table name then need to set shifter SetTableModel() before calling CreateAllEnum()
 func (s *Shifter) CreateAllEnum(conn *pg.DB, model interface{}) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			for enumName := range s.getEnumFromMethod(tableName) {
 				if err = s.createEnumByName(tx, tableName, enumName); err != nil {
 					break
 				}
 			}
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -179,17 +220,12 @@ func (s *Shifter) CreateAllEnum(conn *pg.DB, model interface{}) (err error) {
 //  enumName: enum which you want to upsert
 // if model is table name then need to set shifter SetTableModel() before calling UpsertEnum()
 func (s *Shifter) UpsertEnum(conn *pg.DB, model interface{}, enumName string) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			err = s.upsertEnum(tx, tableName, enumName)
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -201,17 +237,12 @@ func (s *Shifter) UpsertEnum(conn *pg.DB, model interface{}, enumName string) (e
 //  model: struct pointer or string (table name)
 // if model is table name then need to set shifter SetTableModel() before calling UpsertAllEnum()
 func (s *Shifter) UpsertAllEnum(conn *pg.DB, model interface{}) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			err = s.upsertAllEnum(tx, tableName)
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -224,17 +255,12 @@ func (s *Shifter) UpsertAllEnum(conn *pg.DB, model interface{}) (err error) {
 //  skipPrompt: bool (default false | if false then before execution sql it will prompt for confirmation)
 // if model is table name then need to set shifter SetTableModel() before calling DropAllEnum()
 func (s *Shifter) DropAllEnum(conn *pg.DB, model interface{}, skipPrompt bool) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			err = s.dropAllEnum(tx, tableName, skipPrompt)
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -247,17 +273,12 @@ func (s *Shifter) DropAllEnum(conn *pg.DB, model interface{}, skipPrompt bool) (
 //  skipPrompt: bool (default false | if false then before execution sql it will prompt for confirmation)
 // if model is table name then need to set shifter SetTableModel() before calling CreateAllIndex()
 func (s *Shifter) CreateAllIndex(conn *pg.DB, model interface{}, skipPrompt ...bool) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			err = s.createIndex(tx, tableName, getSP(skipPrompt))
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -270,18 +291,13 @@ func (s *Shifter) CreateAllIndex(conn *pg.DB, model interface{}, skipPrompt ...b
 //  skipPrompt: bool (default false | if false then before execution sql it will prompt for confirmation)
 // if model is table name then need to set shifter SetTableModel() before calling CreateAllUniqueKey()
 func (s *Shifter) CreateAllUniqueKey(conn *pg.DB, model interface{}, skipPrompt ...bool) (err error) {
-	var (
-		tx        *pg.Tx
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 			uk := s.getUKFromMethod(tableName)
-			_, err = addCompositeUK(tx, tableName, uk, getSP(skipPrompt))
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			_, err = s.addCompositeUK(tx, tableName, uk, getSP(skipPrompt))
+			return
+		})
 	}
 	return
 }
@@ -297,27 +313,22 @@ func (s *Shifter) CreateAllUniqueKey(conn *pg.DB, model interface{}, skipPrompt
 // If composite unique key exists in table but doesn't exists in struct UniqueKey method
 // then that will be dropped.
 func (s *Shifter) UpsertAllUniqueKey(conn *pg.DB, model interface{}, skipPrompt ...bool) (err error) {
-	var (
-		tx        *pg.Tx
-		tUK       []m.UKSchema
-		tableName string
-	)
+	var tableName string
 	if tableName, err = s.getTableName(model); err == nil {
-		if tx, err = conn.Begin(); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
 
+			var tUK []m.UKSchema
 			if tUK, err = getDBCompositeUniqueKey(tx, tableName); err == nil {
 				sUK := s.getUKFromMethod(tableName)
 				if len(tUK) > 0 || len(sUK) > 0 {
-					if _, err = dropCompositeUK(tx, tableName, tUK, sUK, getSP(skipPrompt)); err == nil {
-						_, err = addCompositeUK(tx, tableName, sUK, getSP(skipPrompt))
+					if _, err = s.dropCompositeUK(tx, tableName, tUK, sUK, getSP(skipPrompt)); err == nil {
+						_, err = s.addCompositeUK(tx, tableName, sUK, getSP(skipPrompt))
 					}
 				}
 			}
 
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-		}
+			return
+		})
 	}
 	return
 }
@@ -325,21 +336,18 @@ func (s *Shifter) UpsertAllUniqueKey(conn *pg.DB, model interface{}, skipPrompt
 //CreateAllTable will create all tables
 //before calling it you need to set the table model in shifter using SetTableModels()
 func (s *Shifter) CreateAllTable(conn *pg.DB) (err error) {
-	for tableName := range s.table {
-		var tx *pg.Tx
-		if tx, err = conn.Begin(); err == nil {
-			if err = s.createTable(tx, tableName, true); err == nil {
-				if err = s.createIndex(tx, tableName, true); err == nil {
-					uk := s.getUKFromMethod(tableName)
-					_, err = addCompositeUK(tx, tableName, uk, true)
-				}
+	err = s.runAllTx(conn, PerTable, func(tx *pg.Tx, tableName string) (err error) {
+		if s.isIgnoredTable(tableName) {
+			return
+		}
+		if err = s.createTable(tx, tableName, true); err == nil {
+			if err = s.createIndex(tx, tableName, true); err == nil {
+				uk := s.getUKFromMethod(tableName)
+				_, err = s.addCompositeUK(tx, tableName, uk, true)
 			}
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-			break
 		}
-	}
+		return
+	})
 	return
 }
 
@@ -348,35 +356,39 @@ func (s *Shifter) CreateAllTable(conn *pg.DB) (err error) {
 func (s *Shifter) AlterAllTable(conn *pg.DB, skipPromt ...bool) (err error) {
 
 	s.Debug(conn)
-	var tx *pg.Tx
-	if tx, err = conn.Begin(); err == nil {
-		for tableName := range s.table {
-			if err = s.alterTable(tx, tableName, getSP(skipPromt)); err != nil {
-				break
+	err = s.withAdvisoryLock(conn, func() (err error) {
+		var unchanged bool
+		fingerprint := s.getFingerprint()
+		//pending steps of failed run are completed first
+		if err = s.resume(conn, getSP(skipPromt), true); err == nil {
+			if unchanged, err = s.isUnchanged(conn, fingerprint); err == nil && unchanged {
+				fmt.Println("Models not changed since last alter, skipping")
+			} else if err == nil {
+				//all the tables are read in a few catalog queries instead of per table queries
+				s.catalog = newCatalog()
+				defer func() { s.catalog = nil }()
+				s.declined = false
+				if err = s.runAllTx(conn, AllOrNothing, func(tx *pg.Tx, tableName string) (err error) {
+					err = s.alterTable(tx, tableName, getSP(skipPromt))
+					return
+				}); err == nil && s.declined == false {
+					//fingerprint is saved only if all the steps are applied
+					err = s.saveFingerprint(conn, fingerprint)
+				}
 			}
 		}
-		commitIfNil(tx, err)
-	} else {
-		err = flaw.TxError(err)
-	}
+		return
+	})
 	return
 }
 
 //DropAllTable will drop all tables
 //before calling it you need to set the table model in shifter using SetTableModels()
 func (s *Shifter) DropAllTable(conn *pg.DB, cascade bool) (err error) {
-	for tableName := range s.table {
-		var tx *pg.Tx
-		if tx, err = conn.Begin(); err == nil {
-			if err = s.dropTable(tx, tableName, cascade); err != nil {
-				break
-			}
-			commitIfNil(tx, err)
-		} else {
-			err = flaw.TxError(err)
-			break
-		}
-	}
+	err = s.runAllTx(conn, PerTable, func(tx *pg.Tx, tableName string) (err error) {
+		err = s.dropTable(tx, tableName, cascade)
+		return
+	})
 	return
 }
 
@@ -5,6 +5,8 @@ import (
 
 	"github.com/mayur-tolexo/contour/adapter/psql"
 	"github.com/mayur-tolexo/pg-shifter/db"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
+	"github.com/mayur-tolexo/pg-shifter/model"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -215,3 +217,169 @@ func TestDropAllEnum(t *testing.T) {
 		assert.NoError(err)
 	}
 }
+
+func TestVerify(t *testing.T) {
+
+	if conn, err := psql.Conn(true); err == nil {
+		s := NewShifter()
+		addAllTables(s)
+		assert := assert.New(t)
+		drift, err := s.Verify(conn)
+		assert.NoError(err)
+		_, err = drift.JSON()
+		assert.NoError(err)
+	}
+}
+
+func TestPlanAllTable(t *testing.T) {
+
+	if conn, err := psql.Conn(true); err == nil {
+		s := NewShifter()
+		addAllTables(s)
+		assert := assert.New(t)
+		plan, err := s.PlanAllTable(conn)
+		assert.NoError(err)
+		for _, step := range plan {
+			assert.NotEmpty(step.Lock)
+			assert.NotEmpty(step.SQL)
+		}
+	}
+}
+
+func TestIgnore(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&db.TestUser{}, &db.TestAddress{})
+	s.Ignore(IgnoreTable, "test_admin_*").
+		Ignore(IgnoreColumn, "analytics_*", "test_user.landline").
+		Ignore(IgnoreIndex, "idx_test_address_*")
+
+	assert.True(s.isIgnoredTable("test_admin_user"))
+	assert.False(s.isIgnoredTable("test_user"))
+	assert.True(s.isIgnoredColumn("test_address", "analytics_score"))
+	assert.True(s.isIgnoredColumn("test_user", "landline"))
+	assert.False(s.isIgnoredColumn("test_address", "landline"))
+	assert.True(s.isIgnoredIndex("test_address", "idx_test_address_status"))
+	assert.False(s.isIgnoredIndex("test_user", "idx_test_user_status"))
+
+	sSchema := s.removeIgnoredColumn("test_user", s.GetStructSchema("test_user"))
+	_, exists := sSchema["landline"]
+	assert.False(exists)
+}
+
+func TestAlterAllTableContinueOnError(t *testing.T) {
+
+	if conn, err := psql.Conn(true); err == nil {
+		s := NewShifter().TxMode(ContinueOnError)
+		addAllTables(s)
+		assert := assert.New(t)
+		err = s.AlterAllTable(conn, true)
+		if allErr, isValid := err.(*AllTableError); isValid {
+			assert.NotEmpty(allErr.Failures)
+		} else {
+			assert.NoError(err)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+
+	if conn, err := psql.Conn(true); err == nil {
+		s := NewShifter()
+		addAllTables(s)
+		assert := assert.New(t)
+		if err = s.CreateAllTable(conn); err == nil {
+			schema, err := inspect.Inspect(conn, "", "test_user")
+			assert.NoError(err)
+			if assert.Equal(1, len(schema.Tables)) {
+				table := schema.Tables[0]
+				assert.Equal("test_user", table.Name)
+				assert.Equal("user_id", table.Columns[0].Name)
+				assert.Equal("integer", table.Columns[0].FullType)
+				assert.NotEmpty(table.Columns[0].SeqName)
+				assert.NotEmpty(table.Constraints)
+			}
+		}
+	}
+}
+
+type testIgnoreModel struct {
+	tableName struct{} `sql:"test_ignore_model"`
+	ID        int      `sql:"id,type:serial primary key"`
+	Status    string   `sql:"status,type:text"`
+	Score     int      `sql:"score,type:int"`
+}
+
+func (testIgnoreModel) Index() map[string]string {
+	return map[string]string{"status": BtreeIndex, "score": BtreeIndex}
+}
+
+func (testIgnoreModel) Ignore() map[string][]string {
+	return map[string][]string{
+		IgnoreColumn: {"score"},
+		IgnoreIndex:  {"idx_test_ignore_model_status"},
+	}
+}
+
+type testIgnoreTableModel struct {
+	tableName struct{} `sql:"test_ignore_table_model"`
+	ID        int      `sql:"id,type:serial primary key"`
+}
+
+func (testIgnoreTableModel) Ignore() map[string][]string {
+	return map[string][]string{IgnoreTable: {"test_ignore_table_*"}}
+}
+
+func TestIgnoreFromMethod(t *testing.T) {
+	assert := assert.New(t)
+	s := NewShifter(&testIgnoreModel{}, &testIgnoreTableModel{})
+	assert.True(s.isIgnoredTable("test_ignore_table_model"))
+	assert.False(s.isIgnoredTable("test_ignore_model"))
+	assert.True(s.isIgnoredColumn("test_ignore_model", "score"))
+	assert.True(s.isIgnoredIndex("test_ignore_model", "idx_test_ignore_model_status"))
+
+	idx := s.removeIgnoredIndex("test_ignore_model", []model.Index{
+		{IdxName: "idx_test_ignore_model_status"}, {IdxName: "idx_test_ignore_model_score"}})
+	if assert.Equal(1, len(idx)) {
+		assert.Equal("idx_test_ignore_model_score", idx[0].IdxName)
+	}
+
+	if conn, err := psql.Conn(true); err == nil {
+		tx, err := conn.Begin()
+		if assert.NoError(err) {
+			defer tx.Rollback()
+			//ignored table is neither created nor dropped
+			assert.NoError(s.createTable(tx, "test_ignore_table_model", false))
+			assert.False(tableExists(tx, "test_ignore_table_model"))
+			assert.NoError(s.createTable(tx, "test_ignore_model", false))
+			assert.NoError(s.createIndex(tx, "test_ignore_model", true))
+			dbIdx, err := getDBIndex(tx, "test_ignore_model")
+			assert.NoError(err)
+			if assert.Equal(1, len(dbIdx)) {
+				assert.Equal("idx_test_ignore_model_score", dbIdx[0].IdxName)
+			}
+		}
+	}
+}
+
+func TestTableStat(t *testing.T) {
+	if conn, err := psql.Conn(true); err == nil {
+		assert := assert.New(t)
+		tx, err := conn.Begin()
+		if assert.NoError(err) {
+			defer tx.Rollback()
+			s := NewShifter()
+			//table which is not created yet
+			stat, err := s.getTableStat(tx, "test_stat_missing")
+			assert.NoError(err)
+			assert.Equal(int64(0), stat.RowCount)
+
+			//never analyzed table is estimated from its size
+			_, err = tx.Exec(`CREATE TABLE test_stat_unanalyzed AS SELECT g AS id FROM generate_series(1, 1000) g`)
+			assert.NoError(err)
+			stat, err = s.getTableStat(tx, "test_stat_unanalyzed")
+			assert.NoError(err)
+			assert.True(stat.RowCount >= 1000)
+			assert.True(stat.Size > 0)
+		}
+	}
+}
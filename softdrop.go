@@ -0,0 +1,413 @@
+package shifter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/model"
+	"github.com/mayur-tolexo/pg-shifter/util"
+)
+
+//trashSchema is schema where soft dropped tables are moved
+const trashSchema = "shifter_trash"
+
+//maxIdentLen is max length of postgresql identifier
+const maxIdentLen = 63
+
+//referenceTable keeps foreign keys of other tables dropped along with soft dropped table
+const referenceTable = "shifter_reference"
+
+//droppedObject is soft dropped table or column
+type droppedObject struct {
+	TableName string `sql:"table_name"`
+	Name      string `sql:"name"`
+}
+
+//droppedReference is foreign key of other table referencing the soft dropped table
+type droppedReference struct {
+	TableName  string `sql:"table_name"`
+	Name       string `sql:"name"`
+	Definition string `sql:"definition"`
+}
+
+// SoftDrop will enable soft drop of tables and columns.
+//
+// Dropped column is renamed to <col>__dropped_<unix time> and dropped table is renamed to
+// <table>__dropped_<unix time> and moved to shifter_trash schema along with its history table.
+// They can be brought back by RestoreTable()/RestoreColumn() and removed by Purge().
+// Foreign keys of other tables dropped by cascade are kept in shifter_trash.shifter_reference
+// and added back by RestoreTable().
+func (s *Shifter) SoftDrop(enable bool) *Shifter {
+	s.softDrop = enable
+	return s
+}
+
+// RestoreTable will restore last soft dropped table along with its history table.
+//
+// Foreign keys of other tables dropped by cascade soft drop are added back
+// if the referencing table still exists.
+//
+// Parameters
+//  conn: postgresql connection
+//  model: struct pointer or string (table name)
+func (s *Shifter) RestoreTable(conn *pg.DB, model interface{}) (err error) {
+	var tableName string
+	if tableName, err = s.getTableName(model); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
+			err = s.restoreTable(tx, tableName)
+			return
+		})
+	}
+	return
+}
+
+// RestoreColumn will restore last soft dropped column of table and its history table.
+//
+// Parameters
+//  conn: postgresql connection
+//  model: struct pointer or string (table name)
+//  column: column name
+func (s *Shifter) RestoreColumn(conn *pg.DB, model interface{}, column string) (err error) {
+	var tableName string
+	if tableName, err = s.getTableName(model); err == nil {
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
+			err = s.restoreColumn(tx, tableName, column)
+			return
+		})
+	}
+	return
+}
+
+// Purge will permanently drop soft dropped tables and columns which are dropped before olderThan duration.
+//
+// Parameters
+//  conn: postgresql connection
+//  olderThan: grace period of soft dropped objects
+func (s *Shifter) Purge(conn *pg.DB, olderThan time.Duration) (err error) {
+	err = s.runTx(conn, func(tx *pg.Tx) (err error) {
+		if err = s.purgeColumn(tx, olderThan); err == nil {
+			err = s.purgeTable(tx, olderThan)
+		}
+		return
+	})
+	return
+}
+
+//softDropCol will drop constraints of column and rename it as dropped
+func (s *Shifter) softDropCol(tx *pg.Tx, schema model.ColSchema,
+	skipPrompt bool) (isAlter bool, err error) {
+
+	dropped := getDroppedName(schema.ColumnName, time.Now())
+	sql := getSoftDropColSQL(schema, dropped)
+	//checking history table exists
+	if s.hisExists {
+		hName := util.GetHistoryTableName(schema.TableName)
+		sql += getSoftDropColSQL(model.ColSchema{TableName: hName, ColumnName: schema.ColumnName}, dropped)
+	}
+	//history alter sql end
+
+	step := Step{Table: schema.TableName, Column: schema.ColumnName, Op: opSoftDropColumn, SQL: sql}
+	if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+		err = getWrapError(schema.TableName, opSoftDropColumn, sql, err)
+	}
+	return
+}
+
+//getSoftDropColSQL will return soft drop column sql.
+//Constraints are dropped so column can be added again with same constraint name
+//and not null is dropped so inserts without the column will not fail
+func getSoftDropColSQL(schema model.ColSchema, dropped string) (sql string) {
+	if schema.ConstraintName != "" {
+		sql += getDropConstraintSQL(schema.TableName, schema.ConstraintName)
+	}
+	if schema.IsFkUnique && schema.FkUniqueName != "" {
+		sql += getDropConstraintSQL(schema.TableName, schema.FkUniqueName)
+	}
+	sql += getNotNullColSQL(schema.TableName, schema.ColumnName, drop) + ";\n"
+	sql += getRenameColSQL(schema.TableName, schema.ColumnName, dropped)
+	return
+}
+
+//getRenameColSQL will return rename column sql
+func getRenameColSQL(tName, cName, newName string) (sql string) {
+	sql = fmt.Sprintf("ALTER TABLE %v RENAME COLUMN %v TO %v;\n", tName, cName, newName)
+	return
+}
+
+//softDropTable will rename table and its history table as dropped and move them to trash schema
+func (s *Shifter) softDropTable(tx *pg.Tx, tableName string, cascade bool) (isDrop bool, err error) {
+	at := time.Now()
+	dropped := getDroppedName(tableName, at)
+	var refSQL string
+	if refSQL, err = getReferenceDropSQL(tx, tableName, dropped, cascade); err == nil {
+		sql := fmt.Sprintf("CREATE SCHEMA IF NOT EXISTS %v;\n", trashSchema) + refSQL
+		sql += getSoftDropTableSQL(tableName, dropped)
+		if hName := util.GetHistoryTableName(tableName); tableExists(tx, hName) {
+			sql += getSoftDropTableSQL(hName, getDroppedName(hName, at))
+		}
+		step := Step{Table: tableName, Op: opSoftDropTable, SQL: sql}
+		if isDrop, err = s.execByChoice(tx, step, true); err != nil {
+			err = getWrapError(tableName, opSoftDropTable, sql, err)
+		} else if isDrop && s.plan == false {
+			fmt.Println("Table soft dropped: ", tableName)
+		}
+	}
+	return
+}
+
+//getSoftDropTableSQL will return rename table as dropped and move to trash schema sql
+func getSoftDropTableSQL(tName, dropped string) (sql string) {
+	sql = fmt.Sprintf("ALTER TABLE %v RENAME TO %v;\nALTER TABLE %v SET SCHEMA %v;\n",
+		tName, dropped, dropped, trashSchema)
+	return
+}
+
+//getReferenceDropSQL will return drop sql of foreign keys of other tables referencing the table.
+//Dropped foreign keys are recorded against dropped name of table to add them back on restore.
+//Without cascade error is returned if table is referenced same as drop table
+func getReferenceDropSQL(tx *pg.Tx, tableName, dropped string, cascade bool) (sql string, err error) {
+	var ref []droppedReference
+	query := `SELECT conrelid::regclass::text AS table_name, conname AS name,
+	pg_get_constraintdef(oid) AS definition
+	FROM pg_constraint WHERE contype = 'f' AND confrelid = ?::regclass AND conrelid <> confrelid;`
+	if _, err = tx.Query(&ref, query, tableName); err != nil {
+		err = getWrapError(tableName, "table reference", query, err)
+	} else if len(ref) > 0 {
+		if cascade == false {
+			err = fmt.Errorf("cannot drop table %v because constraint %v on table %v depends on it",
+				tableName, ref[0].Name, ref[0].TableName)
+		} else {
+			sql = getSaveReferenceSQL(dropped, ref)
+			for _, curRef := range ref {
+				sql += getDropConstraintSQL(curRef.TableName, curRef.Name)
+			}
+		}
+	}
+	return
+}
+
+//getSaveReferenceSQL will return sql to record foreign keys dropped along with the soft dropped table
+func getSaveReferenceSQL(dropped string, ref []droppedReference) (sql string) {
+	sql = fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %v.%v (
+	dropped_name text NOT NULL,
+	table_name text NOT NULL,
+	name text NOT NULL,
+	definition text NOT NULL
+);
+`, trashSchema, referenceTable)
+	for _, curRef := range ref {
+		sql += fmt.Sprintf("INSERT INTO %v.%v VALUES (%v, %v, %v, %v);\n", trashSchema, referenceTable,
+			getLiteral(dropped), getLiteral(curRef.TableName), getLiteral(curRef.Name), getLiteral(curRef.Definition))
+	}
+	return
+}
+
+//getLiteral will return string as quoted sql literal
+func getLiteral(str string) string {
+	return "'" + strings.Replace(str, "'", "''", -1) + "'"
+}
+
+//getReferenceRestoreSQL will return sql to add back foreign keys of other tables
+//which were dropped along with the soft dropped table. Foreign keys of tables which
+//no more exist are skipped
+func getReferenceRestoreSQL(tx *pg.Tx, tableName, dropped string) (sql string, err error) {
+	var ref []droppedReference
+	if tableExists(tx, referenceTable) {
+		query := fmt.Sprintf(`SELECT table_name, name, definition FROM %v.%v
+		WHERE dropped_name = ? AND to_regclass(table_name) IS NOT NULL;`, trashSchema, referenceTable)
+		if _, err = tx.Query(&ref, query, dropped); err != nil {
+			err = getWrapError(tableName, "dropped reference", query, err)
+		} else {
+			for _, curRef := range ref {
+				sql += fmt.Sprintf("ALTER TABLE %v ADD CONSTRAINT %v %v;\n",
+					curRef.TableName, curRef.Name, curRef.Definition)
+			}
+			sql += getDeleteReferenceSQL(dropped)
+		}
+	}
+	return
+}
+
+//getDeleteReferenceSQL will return sql to remove recorded foreign keys of soft dropped table
+func getDeleteReferenceSQL(dropped string) string {
+	return fmt.Sprintf("DELETE FROM %v.%v WHERE dropped_name = %v;\n",
+		trashSchema, referenceTable, getLiteral(dropped))
+}
+
+//restoreTable will move last soft dropped table and its history table back from trash schema
+func (s *Shifter) restoreTable(tx *pg.Tx, tableName string) (err error) {
+	var (
+		dropped   []droppedObject
+		curSchema string
+	)
+	if tableExists(tx, tableName) {
+		err = errors.New("Table already exists: " + tableName)
+	} else if dropped, err = getDroppedTable(tx); err == nil {
+		if _, err = tx.Query(pg.Scan(&curSchema), "SELECT current_schema()"); err == nil {
+			if name, at, exists := getLastDropped(dropped, "", tableName); exists == false {
+				err = errors.New("No soft dropped table found: " + tableName)
+			} else {
+				sql := getRestoreTableSQL(name, tableName, curSchema)
+				hName := util.GetHistoryTableName(tableName)
+				if hDropped := getDroppedName(hName, at); isDropped(dropped, "", hDropped) {
+					sql += getRestoreTableSQL(hDropped, hName, curSchema)
+				}
+				var refSQL string
+				if refSQL, err = getReferenceRestoreSQL(tx, tableName, name); err == nil {
+					sql += refSQL
+					if _, err = tx.Exec(sql); err == nil {
+						fmt.Println("Table restored: ", tableName)
+					} else {
+						err = getWrapError(tableName, "restore table", sql, err)
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+//getRestoreTableSQL will return move table back from trash schema sql
+func getRestoreTableSQL(dropped, tName, schema string) (sql string) {
+	sql = fmt.Sprintf("ALTER TABLE %v.%v SET SCHEMA %v;\nALTER TABLE %v RENAME TO %v;\n",
+		trashSchema, dropped, schema, dropped, tName)
+	return
+}
+
+//restoreColumn will rename last soft dropped column of table and its history table back
+func (s *Shifter) restoreColumn(tx *pg.Tx, tableName, column string) (err error) {
+	var (
+		dropped []droppedObject
+		tSchema map[string]model.ColSchema
+	)
+	if tSchema, err = s.getTableSchema(tx, tableName); err == nil {
+		if _, exists := tSchema[column]; exists {
+			err = errors.New("Column already exists: " + tableName + "." + column)
+		} else if dropped, err = getDroppedColumn(tx); err == nil {
+			if name, _, exists := getLastDropped(dropped, tableName, column); exists == false {
+				err = errors.New("No soft dropped column found: " + tableName + "." + column)
+			} else {
+				sql := getRenameColSQL(tableName, name, column)
+				if hName := util.GetHistoryTableName(tableName); isDropped(dropped, hName, name) {
+					sql += getRenameColSQL(hName, name, column)
+				}
+				step := Step{Table: tableName, Column: column, Op: opRestoreColumn, SQL: sql}
+				if _, err = s.execByChoice(tx, step, true); err != nil {
+					err = getWrapError(tableName, opRestoreColumn, sql, err)
+				}
+			}
+		}
+	}
+	return
+}
+
+//purgeColumn will drop soft dropped columns older than given duration
+func (s *Shifter) purgeColumn(tx *pg.Tx, olderThan time.Duration) (err error) {
+	var dropped []droppedObject
+	if dropped, err = getDroppedColumn(tx); err == nil {
+		for _, curDropped := range dropped {
+			if name, at, isValid := parseDroppedName(curDropped.Name); isValid &&
+				time.Since(at) > olderThan {
+				sql := getDropColSQL(curDropped.TableName, curDropped.Name)
+				step := Step{Table: curDropped.TableName, Column: name, Op: opPurgeColumn, SQL: sql}
+				if _, err = s.execByChoice(tx, step, true); err != nil {
+					err = getWrapError(curDropped.TableName, opPurgeColumn, sql, err)
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+//purgeTable will drop soft dropped tables older than given duration
+func (s *Shifter) purgeTable(tx *pg.Tx, olderThan time.Duration) (err error) {
+	var dropped []droppedObject
+	if dropped, err = getDroppedTable(tx); err == nil {
+		for _, curDropped := range dropped {
+			if name, at, isValid := parseDroppedName(curDropped.Name); isValid &&
+				time.Since(at) > olderThan {
+				sql := getDropTableSQL(trashSchema+"."+curDropped.Name, true) + ";\n"
+				if tableExists(tx, referenceTable) {
+					sql += getDeleteReferenceSQL(curDropped.Name)
+				}
+				step := Step{Table: name, Op: opPurgeTable, SQL: sql}
+				if _, err = s.execByChoice(tx, step, true); err != nil {
+					err = getWrapError(name, opPurgeTable, sql, err)
+					break
+				}
+			}
+		}
+	}
+	return
+}
+
+//getDroppedColumn will return soft dropped columns of current schema
+func getDroppedColumn(tx *pg.Tx) (dropped []droppedObject, err error) {
+	query := `SELECT table_name, column_name AS name FROM information_schema.columns
+	WHERE table_schema = current_schema() AND position(? IN column_name) > 0;`
+	if _, err = tx.Query(&dropped, query, droppedSuffix); err != nil {
+		err = getWrapError("", "dropped column", query, err)
+	}
+	return
+}
+
+//getDroppedTable will return soft dropped tables in trash schema
+func getDroppedTable(tx *pg.Tx) (dropped []droppedObject, err error) {
+	query := `SELECT tablename AS name FROM pg_tables WHERE schemaname = ?;`
+	if _, err = tx.Query(&dropped, query, trashSchema); err != nil {
+		err = getWrapError("", "dropped table", query, err)
+	}
+	return
+}
+
+//getLastDropped will return last dropped name of given table/column.
+//Dropped name is matched with dropped name of given name at its drop time as long name is truncated
+func getLastDropped(dropped []droppedObject, tableName, name string) (
+	lastName string, lastAt time.Time, exists bool) {
+
+	for _, curDropped := range dropped {
+		if curDropped.TableName == tableName {
+			if _, at, isValid := parseDroppedName(curDropped.Name); isValid &&
+				curDropped.Name == getDroppedName(name, at) && at.After(lastAt) {
+				lastName, lastAt, exists = curDropped.Name, at, true
+			}
+		}
+	}
+	return
+}
+
+//isDropped will check given dropped name exists
+func isDropped(dropped []droppedObject, tableName, name string) (flag bool) {
+	for _, curDropped := range dropped {
+		if curDropped.TableName == tableName && curDropped.Name == name {
+			flag = true
+			break
+		}
+	}
+	return
+}
+
+//getDroppedName will return soft dropped name of table/column.
+//Name is truncated to keep it within postgresql identifier length
+func getDroppedName(name string, at time.Time) string {
+	suffix := droppedSuffix + strconv.FormatInt(at.Unix(), 10)
+	if len(name)+len(suffix) > maxIdentLen {
+		name = name[:maxIdentLen-len(suffix)]
+	}
+	return name + suffix
+}
+
+//parseDroppedName will return original name and drop time of soft dropped name
+func parseDroppedName(dropped string) (name string, at time.Time, isValid bool) {
+	if idx := strings.LastIndex(dropped, droppedSuffix); idx > 0 {
+		if unix, err := strconv.ParseInt(dropped[idx+len(droppedSuffix):], 10, 64); err == nil {
+			name, at, isValid = dropped[:idx], time.Unix(unix, 0), true
+		}
+	}
+	return
+}
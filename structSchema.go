@@ -58,7 +58,22 @@ func (s *Shifter) GetStructSchema(tableName string) (sSchema map[string]model.Co
 			schema.ColumnName = getColName(tag)
 			schema.ColumnDefault, schema.DefaultExists = getColDefault(tag)
 			schema.DataType, schema.CharMaxLen = getColType(tag)
+			schema.FullType = formatType(getTagType(tag))
+			if isArrayType(schema.FullType) {
+				//element type and its length are part of full type
+				schema.DataType, schema.CharMaxLen = arrayType, ""
+			}
+			setTypePrecision(&schema)
+			schema.Generated = getColGenerated(tag)
 			schema.IsNullable = getColIsNullable(tag)
+			//identity column is always not null
+			if schema.Identity = getColIdentity(tag); schema.Identity != "" {
+				schema.IsNullable = no
+			}
+			schema.Backfill = field.Tag.Get(BackfillTag)
+			schema.Using = field.Tag.Get(UsingTag)
+			schema.UsingFrom = formatType(field.Tag.Get(UsingFromTag))
+			schema.MigrateFrom = strings.ToLower(field.Tag.Get(MigrateFromTag))
 			s.setColConstraint(&schema, tag)
 			sSchema[schema.ColumnName] = schema
 		}
@@ -71,11 +86,12 @@ func getColName(tag string) string {
 	return strings.Split(tag, ",")[0]
 }
 
-//getColDefault will return col default value from struct tag
+//getColDefault will return col default value from struct tag.
+//Identity clause generated by default is not a default value
 func getColDefault(tag string) (def string, exists bool) {
-	val := strings.Split(tag, "default ")
+	val := strings.Split(identityRegex.ReplaceAllString(tag, ""), "default ")
 	if len(val) > 1 {
-		def = strings.Split(val[1], " ")[0]
+		def = cutTagOption(strings.Split(val[1], " ")[0])
 		exists = true
 	}
 	return
@@ -8,6 +8,7 @@ import (
 	"github.com/go-pg/pg"
 	"github.com/go-pg/pg/orm"
 	"github.com/mayur-tolexo/flaw"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
 	"github.com/mayur-tolexo/pg-shifter/model"
 	"github.com/mayur-tolexo/pg-shifter/util"
 )
@@ -63,12 +64,18 @@ func (s *Shifter) createTableDependencies(tx *pg.Tx, tableModel interface{}) (er
 func (s *Shifter) execTableCreation(tx *pg.Tx, tableName string) (err error) {
 	tableModel := s.table[tableName]
 
+	if s.isIgnoredTable(tableName) {
+		return
+	}
 	exists := false
 	if tableExists(tx, tableName) {
 		exists = true
 	}
 
 	if exists == false {
+		if err = s.beforeCreate(tx, tableName); err != nil {
+			return
+		}
 		if err = tx.CreateTable(tableModel,
 			&orm.CreateTableOptions{IfNotExists: true}); err == nil {
 
@@ -79,6 +86,11 @@ func (s *Shifter) execTableCreation(tx *pg.Tx, tableName string) (err error) {
 					}
 				}
 			}
+			if err == nil {
+				if err = s.baselineMigration(tx, tableName); err == nil {
+					err = s.afterCreate(tx, tableName)
+				}
+			}
 
 			if err == nil {
 				fmt.Println("Table created: ", tableName)
@@ -100,28 +112,40 @@ func (s *Shifter) dropTable(tx *pg.Tx, tableName string, cascade bool) (err erro
 		fData  []byte
 		exists bool
 	)
+	if s.isIgnoredTable(tableName) {
+		return
+	}
 	if log, fData, exists, err = s.generateTableStructSchema(tx, tableName, true); err == nil &&
 		exists {
-		if err = execTableDrop(tx, tableName, cascade); err == nil {
-			if err = s.dropHistory(tx, tableName, cascade); err == nil {
-				err = s.logTableChange(log, fData)
+		var isDrop bool
+		if s.softDrop {
+			isDrop, err = s.softDropTable(tx, tableName, cascade)
+		} else {
+			sql := getDropTableSQL(tableName, cascade) + ";\n"
+			if hName := util.GetHistoryTableName(tableName); tableExists(tx, hName) {
+				sql += getDropTableSQL(hName, cascade) + ";\n"
 			}
+			step := Step{Table: tableName, Op: opDropTable, SQL: sql}
+			if isDrop, err = s.execByChoice(tx, step, true); err != nil {
+				err = getWrapError(tableName, opDropTable, sql, err)
+			} else if isDrop && s.plan == false {
+				fmt.Println("Table Dropped: ", tableName)
+			}
+		}
+		//table is not dropped in plan so its struct is not logged
+		if err == nil && isDrop && s.plan == false {
+			err = s.logTableChange(log, fData)
 		}
 	}
 	return
 }
 
-//execTableDrop will execute table drop
-func execTableDrop(tx *pg.Tx, tableName string, cascade bool) (err error) {
-	sql := fmt.Sprintf("DROP TABLE IF EXISTS %v", tableName)
+//getDropTableSQL will return drop table sql
+func getDropTableSQL(tableName string, cascade bool) (sql string) {
+	sql = fmt.Sprintf("DROP TABLE IF EXISTS %v", tableName)
 	if cascade {
 		sql += " CASCADE"
 	}
-	if _, err = tx.Exec(sql); err == nil {
-		fmt.Println("Table Dropped if exists: ", tableName)
-	} else {
-		err = getWrapError(tableName, "drop table", sql, err)
-	}
 	return
 }
 
@@ -141,41 +165,24 @@ func (s *Shifter) getPostCreateSQLFromMethod(tName string) (sql string) {
 	return
 }
 
-//getConstraint : Get Constraint of table from database
+//getConstraint : Get single column primary, unique and foreign key constraints of table from database
 func getConstraint(tx *pg.Tx, tableName string) (constraint []model.ColSchema, err error) {
-	query := `SELECT tc.constraint_type,
-    tc.constraint_name, tc.is_deferrable, tc.initially_deferred, 
-    kcu.column_name AS column_name, ccu.table_name AS foreign_table_name, 
-    ccu.column_name AS foreign_column_name, pgc.confupdtype, pgc.confdeltype  
-    FROM 
-    information_schema.table_constraints AS tc 
-    JOIN information_schema.key_column_usage AS kcu 
-    ON tc.constraint_name = kcu.constraint_name 
-    JOIN information_schema.constraint_column_usage AS ccu 
-    ON ccu.constraint_name = tc.constraint_name 
-    JOIN pg_constraint AS pgc ON pgc.conname = tc.constraint_name AND 
-    conrelid=?::regclass::oid WHERE tc.constraint_type 
-    IN('FOREIGN KEY','PRIMARY KEY','UNIQUE') AND tc.table_name = ?
-    AND array_length(pgc.conkey,1) = 1;`
-	if _, err = tx.Query(&constraint, query, tableName, tableName); err != nil {
-		err = getWrapError(tableName, "table constraint", query, err)
+	var con []inspect.Constraint
+	if con, err = inspect.Constraints(tx, "", tableName); err == nil {
+		constraint = toConstraintSchema(con)
+	} else {
+		err = getWrapError(tableName, "table constraint", "", err)
 	}
 	return
 }
 
 //getColumnSchema : Get Column Schema of given table
 func getColumnSchema(tx *pg.Tx, tableName string) (columnSchema []model.ColSchema, err error) {
-	query := `SELECT col.column_name, col.column_default, col.data_type,
-	col.ordinal_position as position,
-	col.udt_name, col.is_nullable, col.character_maximum_length 
-	, sq.sequence_name AS seq_name
-	, sq.data_type AS seq_data_type
-	FROM information_schema.columns col
-	left join information_schema.sequences sq
-	ON concat(sq.sequence_schema,'.',sq.sequence_name) = pg_get_serial_sequence(table_name, column_name)
-	WHERE col.table_name = ?;`
-	if _, err = tx.Query(&columnSchema, query, tableName); err != nil {
-		err = getWrapError(tableName, "column schema", query, err)
+	var column []inspect.Column
+	if column, err = inspect.Columns(tx, "", tableName); err == nil {
+		columnSchema = toColumnSchema(column)
+	} else {
+		err = getWrapError(tableName, "column schema", "", err)
 	}
 	return
 }
@@ -3,9 +3,12 @@ package shifter
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"strings"
 
 	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
+	"github.com/mayur-tolexo/pg-shifter/model"
 	"github.com/mayur-tolexo/pg-shifter/util"
 )
 
@@ -15,8 +18,9 @@ func (s *Shifter) createTrigger(tx *pg.Tx, tableName string) (err error) {
 		defer s.logMode(false)
 		trigger := s.GetTrigger(tableName)
 		s.logMode(s.verbose)
-		if _, err = tx.Exec(trigger); err != nil {
-			err = getWrapError(tableName, "create trigger", trigger, err)
+		step := Step{Table: tableName, Op: opCreateTrigger, SQL: trigger}
+		if _, err = s.execByChoice(tx, step, true); err != nil {
+			err = getWrapError(tableName, opCreateTrigger, trigger, err)
 		}
 	}
 	return
@@ -244,6 +248,10 @@ func (s *Shifter) getHistoryFields(dbModel interface{}, dataTag, action string)
 				fields += curField[0] + "," + getNewline(fCount)
 				if curField[0] == "created_at" {
 					values += "NOW()," + getNewline(fCount)
+				} else if getColGenerated(strings.ToLower(tagValue)) != "" {
+					//generated column is plain in history table so its value is inserted.
+					//It changes only with other columns so not part of update condition
+					values += dataTag + "." + curField[0] + "," + getNewline(fCount)
 				} else {
 					uCount++
 					values += dataTag + "." + curField[0] + "," + getNewline(fCount)
@@ -269,3 +277,105 @@ func getNewline(count int) (sep string) {
 	}
 	return
 }
+
+//getTriggerNames will return trigger names which shifter creates on table
+func (s *Shifter) getTriggerNames(tableName string) (names []string) {
+	if dbModel, valid := s.table[tableName]; valid && s.isSkip(tableName) == false {
+		_, _, _, updatedAt, _ := s.getHistoryFields(dbModel, "OLD", "update")
+		for _, curTag := range s.getTableTriggersTag(tableName) {
+			switch curTag {
+			case afterInsertTrigger:
+				names = append(names, util.GetAfterInsertTriggerName(tableName))
+			case afterUpdateTrigger:
+				names = append(names, util.GetAfterUpdateTriggerName(tableName))
+			case afterDeleteTrigger:
+				names = append(names, util.GetAfterDeleteTriggerName(tableName))
+			case beforeUpdateTrigger:
+				if updatedAt {
+					names = append(names, util.GetBeforeInsertTriggerName(tableName))
+				}
+			}
+		}
+	}
+	return
+}
+
+//getDBTrigger : Get trigger names of table from database
+func getDBTrigger(tx *pg.Tx, tableName string) (trigger []string, err error) {
+	var trg []inspect.Trigger
+	if trg, err = inspect.Triggers(tx, "", tableName); err == nil {
+		for _, curTrg := range trg {
+			trigger = append(trigger, curTrg.Name)
+		}
+	} else {
+		err = getWrapError(tableName, "table trigger", "", err)
+	}
+	return
+}
+
+//getSyncTriggerName will return name of trigger which keeps expanding column in sync with old column.
+//Name longer than postgresql identifier limit is truncated and suffixed by its hash to keep it unique
+func getSyncTriggerName(tableName, column string) (name string) {
+	name = tableName + "_" + column + "_sync"
+	if len(name) > 63 {
+		h := fnv.New32a()
+		h.Write([]byte(name))
+		name = fmt.Sprintf("%v_%08x", util.GetStrByLen(name, 55), h.Sum32())
+	}
+	return
+}
+
+//getSyncTrigger will return trigger function and trigger which keeps
+//expanding column in sync with the column it is migrating from.
+//If column is only renamed then old column is kept in sync with new column as well
+func getSyncTrigger(schema model.ColSchema, expr string) (syncTrigger string) {
+
+	syncName := getSyncTriggerName(schema.TableName, schema.ColumnName)
+	newCol, oldCol := schema.ColumnName, schema.MigrateFrom
+	insertReverse, updateReverse := "", ""
+	if expr == oldCol {
+		insertReverse = fmt.Sprintf(`
+        	ELSIF NEW.%v IS NULL THEN
+        		NEW.%v := NEW.%v;`, oldCol, oldCol, newCol)
+		updateReverse = fmt.Sprintf(`
+        	ELSIF NEW.%v IS DISTINCT FROM OLD.%v THEN
+        		NEW.%v := NEW.%v;`, newCol, newCol, oldCol, newCol)
+	}
+	delimiter := `
+	------------------------- SYNC TRIGGER -------------------------`
+
+	fnQuery := fmt.Sprintf(delimiter+`
+	CREATE OR REPLACE FUNCTION %v()
+	RETURNS trigger AS
+	$$
+    	BEGIN
+        	IF TG_OP = 'INSERT' THEN
+        		IF NEW.%v IS NULL THEN
+        			NEW.%v := (SELECT %v FROM (SELECT NEW.*) t);%v
+        		END IF;
+        	ELSIF NEW.%v IS DISTINCT FROM OLD.%v THEN
+        		NEW.%v := (SELECT %v FROM (SELECT NEW.*) t);%v
+        	END IF;
+        	RETURN NEW;
+    	END;
+	$$
+	LANGUAGE 'plpgsql';
+		`, syncName, newCol, newCol, expr, insertReverse,
+		oldCol, oldCol, newCol, expr, updateReverse)
+	triggerQuery := fmt.Sprintf(`
+	DROP TRIGGER IF EXISTS %v ON %v;
+	CREATE TRIGGER %v
+	BEFORE INSERT OR UPDATE ON %v 
+	FOR EACH ROW
+	EXECUTE PROCEDURE %v();`+delimiter,
+		syncName, schema.TableName, syncName, schema.TableName, syncName)
+	syncTrigger = fnQuery + triggerQuery + "\n"
+	return
+}
+
+//getDropSyncTrigger will return drop sync trigger and its function sql
+func getDropSyncTrigger(tableName, column string) string {
+	syncName := getSyncTriggerName(tableName, column)
+	return fmt.Sprintf("DROP TRIGGER IF EXISTS %v ON %v;\nDROP FUNCTION IF EXISTS %v();\n",
+		syncName, tableName, syncName)
+}
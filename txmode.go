@@ -0,0 +1,132 @@
+package shifter
+
+import (
+	"fmt"
+
+	"github.com/go-pg/pg"
+)
+
+//transaction modes of all table operations
+const (
+	AllOrNothing    = "all-or-nothing"    //all tables in one transaction
+	PerTable        = "per-table"         //each table in its own transaction, stops at first failed table
+	ContinueOnError = "continue-on-error" //all tables in one transaction, failed table is rolled back to its savepoint
+)
+
+//tableSavepoint is savepoint of each table in continue on error mode
+const tableSavepoint = "shifter_table"
+
+//TableError is failure of a table in continue on error mode
+type TableError struct {
+	Table string `json:"table"`
+	Err   error  `json:"error"`
+}
+
+//AllTableError is returned in continue on error mode when some of the tables failed.
+//Changes of other tables are committed
+type AllTableError struct {
+	Failures []TableError
+}
+
+//Error will list all the failed tables with their error
+func (e *AllTableError) Error() string {
+	msg := fmt.Sprintf("%v table failed", len(e.Failures))
+	for _, f := range e.Failures {
+		msg += fmt.Sprintf("\n%v: %v", f.Table, f.Err.Error())
+	}
+	return msg
+}
+
+// TxMode will set transaction mode of AlterAllTable, CreateAllTable, DropAllTable and ContractAllTable.
+//
+// AllOrNothing: all tables in one transaction (default of AlterAllTable and ContractAllTable)
+// PerTable: each table in its own transaction, stops at first failed table (default of CreateAllTable and DropAllTable)
+// ContinueOnError: all tables in one transaction with a savepoint per table. Failed table is rolled back
+// to its savepoint and others are committed. Failures are returned as AllTableError
+func (s *Shifter) TxMode(mode string) *Shifter {
+	s.txMode = mode
+	return s
+}
+
+//runAllTx will run fn for all the tables as per transaction mode
+func (s *Shifter) runAllTx(conn *pg.DB, defaultMode string,
+	fn func(tx *pg.Tx, tableName string) error) (err error) {
+
+	mode := s.txMode
+	if mode == "" {
+		mode = defaultMode
+	}
+	tables := s.getTableNames()
+	err = s.withAdvisoryLock(conn, func() error {
+		return s.runModeTx(conn, mode, tables, fn)
+	})
+	return
+}
+
+//runModeTx will run fn for given tables as per transaction mode
+func (s *Shifter) runModeTx(conn *pg.DB, mode string, tables []string,
+	fn func(tx *pg.Tx, tableName string) error) (err error) {
+
+	switch mode {
+	case PerTable:
+		for _, tableName := range tables {
+			if err = s.runTx(conn, func(tx *pg.Tx) error {
+				return fn(tx, tableName)
+			}); err != nil {
+				break
+			}
+		}
+	case ContinueOnError:
+		var failures []TableError
+		if err = s.runTx(conn, func(tx *pg.Tx) (err error) {
+			failures = nil
+			for _, tableName := range tables {
+				var tErr error
+				if tErr, err = s.savepointTx(tx, func() error {
+					return fn(tx, tableName)
+				}); err != nil {
+					break
+				} else if tErr != nil {
+					failures = append(failures, TableError{Table: tableName, Err: tErr})
+				}
+			}
+			return
+		}); err == nil && len(failures) > 0 {
+			err = &AllTableError{Failures: failures}
+			s.saveFailedStep(conn, err)
+		}
+	default:
+		err = s.runTx(conn, func(tx *pg.Tx) (err error) {
+			for _, tableName := range tables {
+				if err = fn(tx, tableName); err != nil {
+					break
+				}
+			}
+			return
+		})
+	}
+	return
+}
+
+//savepointTx will run fn inside a savepoint. If fn fails or any of its step is blocked
+//then changes are rolled back to the savepoint and returned as fnErr so transaction can continue.
+//Steps and tables/enums marked as created by fn are discarded along with the changes
+func (s *Shifter) savepointTx(tx *pg.Tx, fn func() error) (fnErr, err error) {
+	steps, deferred := len(s.steps), len(s.deferred)
+	created := saveCreated()
+	if _, err = tx.Exec("SAVEPOINT " + tableSavepoint); err == nil {
+		if fnErr = fn(); fnErr == nil {
+			if fnErr = s.getPreCheckError(); fnErr == nil {
+				fnErr = s.getBlockedError()
+			}
+		}
+		if fnErr != nil {
+			s.steps, s.deferred, s.blocked, s.failed = s.steps[:steps], s.deferred[:deferred], nil, nil
+			restoreCreated(created)
+			_, err = tx.Exec("ROLLBACK TO SAVEPOINT " + tableSavepoint)
+		} else {
+			_, err = tx.Exec("RELEASE SAVEPOINT " + tableSavepoint)
+		}
+	}
+	return
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/pg-shifter/inspect"
 	"github.com/mayur-tolexo/pg-shifter/model"
 	"github.com/mayur-tolexo/pg-shifter/util"
 )
@@ -35,9 +36,9 @@ func (s *Shifter) getUKFromMethod(tName string) (uk map[string]string) {
 func (s *Shifter) checkUniqueKeyToAlter(tx *pg.Tx, tName string,
 	tUK []model.UKSchema, sUK map[string]string) (isAlter bool, err error) {
 
-	if isAlter, err = dropCompositeUK(tx, tName, tUK, sUK, true); err == nil {
+	if isAlter, err = s.dropCompositeUK(tx, tName, tUK, sUK, true); err == nil {
 		var curAlter bool
-		curAlter, err = addCompositeUK(tx, tName, sUK, true)
+		curAlter, err = s.addCompositeUK(tx, tName, sUK, true)
 		isAlter = isAlter || curAlter
 	}
 
@@ -45,20 +46,23 @@ func (s *Shifter) checkUniqueKeyToAlter(tx *pg.Tx, tName string,
 }
 
 //addCompositeUK will add composite unique key which is not in table
-func addCompositeUK(tx *pg.Tx, tName string, sUK map[string]string, skipPrompt bool) (
+func (s *Shifter) addCompositeUK(tx *pg.Tx, tName string, sUK map[string]string, skipPrompt bool) (
 	isAlter bool, err error) {
 
 	if len(sUK) > 0 {
 		sql := ""
+		var checks []dataCheck
 		for ukName, ukFields := range sUK {
 			//only for more than one fields
 			if isCompositeUk(ukFields) {
 				sql += getUniqueKeyQuery(tName, ukName, ukFields)
+				checks = append(checks, getDuplicateCheck(tName, getUKColumns(ukFields)))
 			}
 		}
 		if sql != "" {
-			if isAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-				err = getWrapError(tName, "add composite unique key", sql, err)
+			step := Step{Table: tName, Op: opAddCompositeUK, Scan: true, SQL: sql, checks: checks}
+			if isAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+				err = getWrapError(tName, opAddCompositeUK, sql, err)
 			}
 		}
 	}
@@ -66,7 +70,7 @@ func addCompositeUK(tx *pg.Tx, tName string, sUK map[string]string, skipPrompt b
 }
 
 //dropCompositeUK will drop composite unique key if not exists in struct
-func dropCompositeUK(tx *pg.Tx, tName string, tUK []model.UKSchema,
+func (s *Shifter) dropCompositeUK(tx *pg.Tx, tName string, tUK []model.UKSchema,
 	sUK map[string]string, skipPrompt bool) (isAlter bool, err error) {
 
 	for _, curTableUK := range tUK {
@@ -76,8 +80,9 @@ func dropCompositeUK(tx *pg.Tx, tName string, tUK []model.UKSchema,
 			delete(sUK, curTableUK.ConstraintName)
 		} else {
 			sql := getDropConstraintSQL(tName, curTableUK.ConstraintName)
-			if curAlter, err = execByChoice(tx, sql, skipPrompt); err != nil {
-				err = getWrapError(tName, "drop composite unique key", sql, err)
+			step := Step{Table: tName, Op: opDropCompositeUK, SQL: sql}
+			if curAlter, err = s.execByChoice(tx, step, skipPrompt); err != nil {
+				err = getWrapError(tName, opDropCompositeUK, sql, err)
 				break
 			}
 		}
@@ -94,6 +99,14 @@ func isCompositeUk(fields string) (isComposite bool) {
 	return
 }
 
+//getUKColumns will return columns of unique key fields
+func getUKColumns(fields string) (columns []string) {
+	for _, col := range strings.Split(fields, ",") {
+		columns = append(columns, strings.TrimSpace(col))
+	}
+	return
+}
+
 //Get unique key query by tablename, unique key constraing name and table columns
 func getUniqueKeyQuery(tableName string, constraintName string,
 	column string) (uniqueKeyQuery string) {
@@ -103,20 +116,9 @@ func getUniqueKeyQuery(tableName string, constraintName string,
 
 //getDBCompositeUniqueKey : Get composite unique key name and columns from database
 func getDBCompositeUniqueKey(tx *pg.Tx, tableName string) (ukSchema []model.UKSchema, err error) {
-	query := `
-	with comp as (
-		select  c.column_name, pgc.conname
-		, array_position(pgc.conkey::int[],c.ordinal_position::int) as position
-		from pg_constraint as pgc join
-		information_schema.table_constraints tc on pgc.conname = tc.constraint_name,
-		unnest(pgc.conkey::int[]) as colNo join information_schema.columns as c
-		on c.ordinal_position = colNo and c.table_name = ?
-		where array_length(pgc.conkey,1)>1 and pgc.contype='u'
-		and pgc.conrelid=c.table_name::regclass::oid
-		order by position
-	)
-	select string_agg(column_name,',') as col, conname
-	from comp group by conname;`
-	_, err = tx.Query(&ukSchema, query, tableName)
+	var con []inspect.Constraint
+	if con, err = inspect.Constraints(tx, "", tableName); err == nil {
+		ukSchema = toUKSchema(con)
+	}
 	return
 }
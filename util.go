@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/flaw"
+	"github.com/mayur-tolexo/pg-shifter/util"
 )
 
 //getStructTableName will return table name from table struct
@@ -97,6 +101,15 @@ func (s *Shifter) getTableName(model interface{}) (
 	return
 }
 
+//getTableNames will return table names set in shifter in sorted order
+func (s *Shifter) getTableNames() (tables []string) {
+	for tableName := range s.table {
+		tables = append(tables, tableName)
+	}
+	sort.Strings(tables)
+	return
+}
+
 //getSP will return skip prompt value
 func getSP(val []bool) (skipPrompt bool) {
 	if len(val) > 0 {
@@ -105,6 +118,20 @@ func getSP(val []bool) (skipPrompt bool) {
 	return
 }
 
+//getChoice will return choice of user to execute sql.
+//Choice is kept for the run so sql is not asked again when transaction is retried
+func (s *Shifter) getChoice(sql string, skipPrompt bool) (choice string) {
+	var exists bool
+	if choice, exists = s.choice[sql]; exists == false {
+		choice = util.GetChoice(sql, skipPrompt)
+		if s.choice == nil {
+			s.choice = make(map[string]string)
+		}
+		s.choice[sql] = choice
+	}
+	return
+}
+
 //commitIfNil will commit transation if error is nil
 func commitIfNil(tx *pg.Tx, err error) {
 	if err == nil {
@@ -113,3 +140,64 @@ func commitIfNil(tx *pg.Tx, err error) {
 		tx.Rollback()
 	}
 }
+
+//runTx will run fn in a transaction and commit it
+//if there is no error and no step is blocked by policy.
+//Steps deferred by online mode are executed after commit.
+//Advisory lock is held till deferred steps are executed
+func (s *Shifter) runTx(conn *pg.DB, fn func(tx *pg.Tx) error) (err error) {
+	err = s.withAdvisoryLock(conn, func() (err error) {
+		if err = s.retryTx(conn, fn); err == nil {
+			err = s.runDeferred(conn, true)
+		} else {
+			s.saveFailedStep(conn, err)
+		}
+		return
+	})
+	return
+}
+
+//retryTx will run fn in a transaction.
+//If lock is not available then transaction is retried with backoff.
+//Choices given in prompt are kept for the retry so user is not asked again
+func (s *Shifter) retryTx(conn *pg.DB, fn func(tx *pg.Tx) error) (err error) {
+	s.choice = nil
+	for attempt := 0; ; attempt++ {
+		if err = s.execTx(conn, fn); err == nil || attempt >= s.retry || isRetryable(err) == false {
+			break
+		}
+		wait := s.backoff << uint(attempt)
+		fmt.Printf("Lock not available, retry %v/%v in %v\n%v\n", attempt+1, s.retry, wait, err)
+		time.Sleep(wait)
+	}
+	return
+}
+
+//execTx will run fn in a transaction with timeout and commit it
+//if there is no error and no step is blocked by policy
+func (s *Shifter) execTx(conn *pg.DB, fn func(tx *pg.Tx) error) (err error) {
+	var tx *pg.Tx
+	if tx, err = conn.Begin(); err == nil {
+		//tables and enums created in rolled back transaction are created again on retry
+		created := saveCreated()
+		defer func() {
+			if err != nil {
+				restoreCreated(created)
+			}
+		}()
+		s.blocked, s.checked, s.deferred, s.failed, s.steps = nil, nil, nil, nil, nil
+		if err = s.setTimeout(tx); err == nil {
+			if err = fn(tx); err == nil {
+				if err = s.getPreCheckError(); err == nil {
+					if err = s.getBlockedError(); err == nil {
+						err = s.saveCheckpoint(tx)
+					}
+				}
+			}
+		}
+		commitIfNil(tx, err)
+	} else {
+		err = flaw.TxError(err)
+	}
+	return
+}
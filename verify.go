@@ -0,0 +1,406 @@
+package shifter
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/go-pg/pg"
+	"github.com/mayur-tolexo/flaw"
+	"github.com/mayur-tolexo/pg-shifter/model"
+	"github.com/mayur-tolexo/pg-shifter/util"
+)
+
+//drift object kind
+const (
+	DriftTable      = "table"      //table or history table drift
+	DriftColumn     = "column"     //column drift
+	DriftConstraint = "constraint" //composite unique key drift
+	DriftIndex      = "index"      //index drift
+	DriftEnum       = "enum"       //enum drift
+	DriftTrigger    = "trigger"    //trigger drift
+)
+
+//drift operation
+const (
+	DriftMissing  = "missing"  //exists in struct but not in database
+	DriftExtra    = "extra"    //exists in database but not in struct
+	DriftModified = "modified" //exists in both but not same
+)
+
+//Drift is the difference between database and the table structs set in shifter
+type Drift struct {
+	Tables []TableDrift `json:"tables"`
+}
+
+//TableDrift is the difference between database table and its struct
+type TableDrift struct {
+	Table      string       `json:"table"`
+	Difference []Difference `json:"difference"`
+}
+
+//Difference is a single object which is not same in database and struct
+type Difference struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Op     string `json:"op"`
+	Field  string `json:"field,omitempty"`
+	DB     string `json:"db,omitempty"`
+	Struct string `json:"struct,omitempty"`
+}
+
+//HasDrift will return true if any table is not same as its struct
+func (d Drift) HasDrift() bool {
+	return len(d.Tables) > 0
+}
+
+//JSON will return drift as json
+func (d Drift) JSON() ([]byte, error) {
+	return json.MarshalIndent(d, "", "  ")
+}
+
+// Verify will compare all the table structs set in shifter with database.
+//
+// It only reads the database catalog in a read only transaction
+// so it will never prompt or execute any ddl.
+// Before calling it you need to set the table model in shifter using SetTableModels()
+func (s *Shifter) Verify(conn *pg.DB) (drift Drift, err error) {
+	var tx *pg.Tx
+	if tx, err = conn.Begin(); err == nil {
+		if _, err = tx.Exec("SET TRANSACTION READ ONLY"); err == nil {
+			for _, tableName := range s.getTableNames() {
+				var tDrift TableDrift
+				if s.isIgnoredTable(tableName) {
+					continue
+				}
+				if tDrift, err = s.verifyTable(tx, tableName); err != nil {
+					break
+				}
+				if len(tDrift.Difference) > 0 {
+					drift.Tables = append(drift.Tables, tDrift)
+				}
+			}
+		}
+		tx.Rollback()
+	} else {
+		err = flaw.TxError(err)
+	}
+	return
+}
+
+//verifyTable will return difference between database table and struct
+func (s *Shifter) verifyTable(tx *pg.Tx, tableName string) (
+	tDrift TableDrift, err error) {
+
+	var (
+		diff    []Difference
+		tSchema map[string]model.ColSchema
+	)
+	tDrift.Table = tableName
+
+	if diff, err = s.verifyEnum(tx, tableName); err == nil {
+		tDrift.Difference = append(tDrift.Difference, diff...)
+
+		if tableExists(tx, tableName) == false {
+			tDrift.Difference = append(tDrift.Difference, Difference{
+				Kind: DriftTable, Name: tableName, Op: DriftMissing})
+		} else if tSchema, err = s.getTableSchema(tx, tableName); err == nil {
+			tSchema = s.removeIgnoredColumn(tableName, tSchema)
+			sSchema := s.removeIgnoredColumn(tableName, s.GetStructSchema(tableName))
+			removeExpandColumn(tSchema, sSchema)
+			if diff, err = s.verifyColumn(tx, tSchema, sSchema); err == nil {
+				tDrift.Difference = append(tDrift.Difference, diff...)
+
+				if diff, err = s.verifyUniqueKey(tx, tableName); err == nil {
+					tDrift.Difference = append(tDrift.Difference, diff...)
+
+					if diff, err = s.verifyIndex(tx, tableName); err == nil {
+						tDrift.Difference = append(tDrift.Difference, diff...)
+
+						if diff, err = s.verifyTrigger(tx, tableName); err == nil {
+							tDrift.Difference = append(tDrift.Difference, diff...)
+						}
+					}
+				}
+			}
+		}
+	}
+	return
+}
+
+//verifyColumn will return column difference between table and struct schema
+func (s *Shifter) verifyColumn(tx *pg.Tx, tSchema, sSchema map[string]model.ColSchema) (
+	diff []Difference, err error) {
+
+	for _, col := range getSortedColumn(tSchema, sSchema) {
+		tcSchema, tExists := tSchema[col]
+		scSchema, sExists := sSchema[col]
+
+		if tExists == false {
+			diff = append(diff, Difference{Kind: DriftColumn, Name: col, Op: DriftMissing,
+				Struct: getAddColTypeSQL(scSchema)})
+		} else if sExists == false {
+			diff = append(diff, Difference{Kind: DriftColumn, Name: col, Op: DriftExtra,
+				DB: getAddColTypeSQL(tcSchema)})
+		} else {
+			var colDiff []Difference
+			if colDiff, err = s.getColumnDiff(tx, tcSchema, scSchema); err != nil {
+				break
+			}
+			diff = append(diff, colDiff...)
+		}
+	}
+	return
+}
+
+//getColumnDiff will return modified fields of a column
+//comparision is same as done while altering the column
+func (s *Shifter) getColumnDiff(tx *pg.Tx, tSchema, sSchema model.ColSchema) (diff []Difference, err error) {
+
+	addDiff := func(field, dbVal, structVal string) {
+		diff = append(diff, Difference{Kind: DriftColumn, Name: sSchema.ColumnName,
+			Op: DriftModified, Field: field, DB: dbVal, Struct: structVal})
+	}
+
+	if isSameType(tSchema, sSchema) == false {
+		addDiff("data_type", getColumnType(tSchema), getColumnType(sSchema))
+	}
+	var isSame bool
+	if isSame, err = s.isSameColDefault(tx, tSchema, sSchema); err == nil &&
+		tSchema.ConstraintType != primaryKey && isSame == false {
+		addDiff("default", tSchema.ColumnDefault, sSchema.ColumnDefault)
+	}
+	if isSameGeneratedExpr(tSchema, sSchema) == false {
+		addDiff("generated", tSchema.Generated, sSchema.Generated)
+	}
+	if tSchema.Identity != sSchema.Identity {
+		addDiff("identity", tSchema.Identity, sSchema.Identity)
+	}
+	if tSchema.IsNullable != sSchema.IsNullable {
+		addDiff("is_nullable", tSchema.IsNullable, sSchema.IsNullable)
+	}
+	if tSchema.ConstraintType != sSchema.ConstraintType {
+		addDiff("constraint_type", tSchema.ConstraintType, sSchema.ConstraintType)
+	} else if tSchema.ConstraintType == foreignKey {
+		if tSchema.IsFkUnique != sSchema.IsFkUnique {
+			addDiff("unique", getUniqueDTypeSQL(tSchema), getUniqueDTypeSQL(sSchema))
+		}
+		if tSchema.ForeignTableName != sSchema.ForeignTableName ||
+			tSchema.ForeignColumnName != sSchema.ForeignColumnName ||
+			tSchema.UpdateType != sSchema.UpdateType ||
+			tSchema.DeleteType != sSchema.DeleteType {
+			addDiff("references", getStructConstraintSQL(tSchema), getStructConstraintSQL(sSchema))
+		}
+	}
+	if tSchema.ConstraintType != "" && sSchema.ConstraintType != "" &&
+		(tSchema.IsDeferrable != sSchema.IsDeferrable ||
+			tSchema.InitiallyDeferred != sSchema.InitiallyDeferred) {
+		addDiff("deferrable", getDefferSQL(tSchema), getDefferSQL(sSchema))
+	}
+	return
+}
+
+//verifyUniqueKey will return composite unique key difference between table and struct
+func (s *Shifter) verifyUniqueKey(tx *pg.Tx, tableName string) (
+	diff []Difference, err error) {
+
+	var tUK []model.UKSchema
+	if tUK, err = getDBCompositeUniqueKey(tx, tableName); err == nil {
+		sUK := s.getUKFromMethod(tableName)
+		tUKMap := make(map[string]string)
+		for _, curUK := range tUK {
+			tUKMap[curUK.ConstraintName] = curUK.Columns
+		}
+		for _, ukName := range getSortedKey(tUKMap, sUK) {
+			tFields, tExists := tUKMap[ukName]
+			sFields, sExists := sUK[ukName]
+			sFields = strings.Replace(sFields, " ", "", -1)
+
+			if sExists && isCompositeUk(sFields) == false {
+				continue
+			} else if tExists == false {
+				diff = append(diff, Difference{Kind: DriftConstraint, Name: ukName,
+					Op: DriftMissing, Struct: sFields})
+			} else if sExists == false {
+				diff = append(diff, Difference{Kind: DriftConstraint, Name: ukName,
+					Op: DriftExtra, DB: tFields})
+			} else if tFields != sFields {
+				diff = append(diff, Difference{Kind: DriftConstraint, Name: ukName,
+					Op: DriftModified, Field: "columns", DB: tFields, Struct: sFields})
+			}
+		}
+	}
+	return
+}
+
+//verifyIndex will return index difference between table and struct
+func (s *Shifter) verifyIndex(tx *pg.Tx, tableName string) (
+	diff []Difference, err error) {
+
+	var idx []model.Index
+	if idx, err = getDBIndex(tx, tableName); err == nil {
+		tIdx := make(map[string]model.Index)
+		for _, curIdx := range idx {
+			if s.isIgnoredIndex(tableName, curIdx.IdxName) == false {
+				tIdx[curIdx.IdxName] = curIdx
+			}
+		}
+		sIdx := make(map[string]model.Index)
+		for column, idxType := range s.getIndexFromMethod(tableName) {
+			idxName := getIndexName(tableName, column)
+			if s.isIgnoredIndex(tableName, idxName) {
+				continue
+			}
+			sIdx[idxName] = model.Index{IdxName: idxName, IType: getIndexType(idxType),
+				Columns: strings.Replace(column, " ", "", -1)}
+		}
+
+		for _, idxName := range getSortedIndex(tIdx, sIdx) {
+			tCurIdx, tExists := tIdx[idxName]
+			sCurIdx, sExists := sIdx[idxName]
+
+			if tExists == false {
+				diff = append(diff, Difference{Kind: DriftIndex, Name: idxName,
+					Op: DriftMissing, Struct: sCurIdx.Columns})
+			} else if sExists == false {
+				diff = append(diff, Difference{Kind: DriftIndex, Name: idxName,
+					Op: DriftExtra, DB: tCurIdx.Columns})
+			} else if tCurIdx.Columns != sCurIdx.Columns {
+				diff = append(diff, Difference{Kind: DriftIndex, Name: idxName,
+					Op: DriftModified, Field: "columns", DB: tCurIdx.Columns, Struct: sCurIdx.Columns})
+			} else if tCurIdx.IType != sCurIdx.IType {
+				diff = append(diff, Difference{Kind: DriftIndex, Name: idxName,
+					Op: DriftModified, Field: "type", DB: tCurIdx.IType, Struct: sCurIdx.IType})
+			}
+		}
+	}
+	return
+}
+
+//getEnumDiff will return drift of enum values.
+//Values missing in database, extra values in database and different order are reported
+func getEnumDiff(enumName string, tEnumValue, sEnumValue []string) (diff []Difference) {
+	tValue, sValue := strings.Join(tEnumValue, ","), strings.Join(sEnumValue, ",")
+	if tValue != sValue {
+		field := "values"
+		if len(tEnumValue) == len(sEnumValue) {
+			sEnumMap := make(map[string]struct{})
+			for _, curVal := range sEnumValue {
+				sEnumMap[curVal] = struct{}{}
+			}
+			field = "order"
+			for _, curVal := range tEnumValue {
+				if _, exists := sEnumMap[curVal]; exists == false {
+					field = "values"
+					break
+				}
+			}
+		}
+		diff = append(diff, Difference{Kind: DriftEnum, Name: enumName, Op: DriftModified,
+			Field: field, DB: tValue, Struct: sValue})
+	}
+	return
+}
+
+//verifyEnum will return enum difference between database and struct
+func (s *Shifter) verifyEnum(tx *pg.Tx, tableName string) (
+	diff []Difference, err error) {
+
+	enumVisited := make(map[string]struct{})
+	for _, field := range util.GetStructField(s.table[tableName]) {
+		enumName := util.FieldType(field)
+		if _, visited := enumVisited[enumName]; visited || s.isEnum(tableName, enumName) == false {
+			continue
+		}
+		enumVisited[enumName] = struct{}{}
+
+		var sEnumValue, tEnumValue []string
+		if sEnumValue, err = s.getEnum(tableName, enumName); err != nil {
+			break
+		}
+		if dbEnumExists(tx, enumName) == false {
+			diff = append(diff, Difference{Kind: DriftEnum, Name: enumName,
+				Op: DriftMissing, Struct: strings.Join(sEnumValue, ",")})
+		} else if tEnumValue, err = getDBEnumValue(tx, enumName); err == nil {
+			diff = append(diff, getEnumDiff(enumName, tEnumValue, sEnumValue)...)
+		} else {
+			break
+		}
+	}
+	sort.Slice(diff, func(i, j int) bool {
+		return diff[i].Name < diff[j].Name
+	})
+	return
+}
+
+//verifyTrigger will return history table and trigger difference between database and struct
+func (s *Shifter) verifyTrigger(tx *pg.Tx, tableName string) (
+	diff []Difference, err error) {
+
+	var tTrigger []string
+	if sTrigger := s.getTriggerNames(tableName); len(sTrigger) > 0 {
+
+		historyTable := util.GetHistoryTableName(tableName)
+		if tableExists(tx, historyTable) == false {
+			diff = append(diff, Difference{Kind: DriftTable, Name: historyTable, Op: DriftMissing})
+		}
+
+		if tTrigger, err = getDBTrigger(tx, tableName); err == nil {
+			tTriggerMap := make(map[string]struct{})
+			for _, curTrigger := range tTrigger {
+				tTriggerMap[curTrigger] = struct{}{}
+			}
+			for _, curTrigger := range sTrigger {
+				if _, exists := tTriggerMap[curTrigger]; exists == false {
+					diff = append(diff, Difference{Kind: DriftTrigger, Name: curTrigger, Op: DriftMissing})
+				}
+			}
+		}
+	}
+	return
+}
+
+//getSortedColumn will return union of table and struct columns in sorted order
+func getSortedColumn(tSchema, sSchema map[string]model.ColSchema) (cols []string) {
+	colMap := make(map[string]struct{})
+	for col := range tSchema {
+		colMap[col] = struct{}{}
+	}
+	for col := range sSchema {
+		colMap[col] = struct{}{}
+	}
+	return getSortedSet(colMap)
+}
+
+//getSortedKey will return union of table and struct unique keys in sorted order
+func getSortedKey(tUK, sUK map[string]string) (keys []string) {
+	keyMap := make(map[string]struct{})
+	for key := range tUK {
+		keyMap[key] = struct{}{}
+	}
+	for key := range sUK {
+		keyMap[key] = struct{}{}
+	}
+	return getSortedSet(keyMap)
+}
+
+//getSortedIndex will return union of table and struct index in sorted order
+func getSortedIndex(tIdx, sIdx map[string]model.Index) (idx []string) {
+	idxMap := make(map[string]struct{})
+	for key := range tIdx {
+		idxMap[key] = struct{}{}
+	}
+	for key := range sIdx {
+		idxMap[key] = struct{}{}
+	}
+	return getSortedSet(idxMap)
+}
+
+//getSortedSet will return set values in sorted order
+func getSortedSet(set map[string]struct{}) (val []string) {
+	for key := range set {
+		val = append(val, key)
+	}
+	sort.Strings(val)
+	return
+}